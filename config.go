@@ -0,0 +1,628 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kataras/rizla/rizla"
+)
+
+// ConfigFilename is the name of the configuration file
+// which rizla looks for, in the working directory, when no *.go files or packages are given.
+// Create one with the `rizla init` command.
+//
+// Example:
+//
+//	watcher = "signal"
+//	onreload = ["./on_reload.sh"]
+//	onreloaded = ["./warm_cache.sh"]
+//
+//	[[project]]
+//	name = "api"
+//	main = "./cmd/api/main.go"
+//	args = ["-port", "8080"]
+//	allow-reload-after = "3s"
+//	include = ["*.go", "*.html"]
+//	exclude = ["*_test.go"]
+//	exclude-dirs = [".git", "node_modules", "vendor"]
+const ConfigFilename = "rizla.toml"
+
+// config is the representation of the `ConfigFilename` file.
+//
+// Fields with a `usage` tag are also available as command line flags,
+// their name is the `toml` tag.
+type config struct {
+	// Watcher is the file system's watcher, "signal" (default) or "walk".
+	Watcher string `toml:"watcher" usage:"the file system's watcher, \"signal\" or \"walk\""`
+	// Interval is the polling interval of the "walk" watcher, see `rizla.WatcherFromFlag`.
+	Interval time.Duration `toml:"interval" usage:"the polling interval of the walk watcher (default 1.35s)"`
+	// OnReload commands to execute before each reload, see `rizla.OnReloadScripts`.
+	OnReload []string `toml:"onreload" usage:"comma separated commands to execute before each reload"`
+	// OnReloaded commands to execute after each reload, see `rizla.OnReloadedScripts`.
+	OnReloaded []string `toml:"onreloaded" usage:"comma separated commands to execute after each reload, when the program has started or it's ready"`
+	// HookPolicy is the name of the `rizla.DefaultHookPolicy`, the failure policy of the onreload and onreloaded commands
+	// and of the hooks without a policy.
+	HookPolicy  string        `toml:"hook-policy" usage:"what happens when an onreload or onreloaded command fails: \"abort\" the reload, \"continue\" or \"exit\" rizla (default abort)"`
+	HookTimeout time.Duration `toml:"hook-timeout" usage:"the onreload and onreloaded commands are killed if they run longer than that (default 1m)"`
+
+	Projects []projectConfig `toml:"project"`
+}
+
+// projectConfig is the representation of a [[project]] entry of the `ConfigFilename` file.
+type projectConfig struct {
+	Name string `toml:"name" usage:"optional name of the project, shown on rizla's messages"`
+	// Main is the go project's main file or main package, relative to the configuration file,
+	// i.e "main.go", "./cmd/api" or "example.com/svc/cmd/worker".
+	Main string   `toml:"main"`
+	Args []string `toml:"args"`
+	// WorkDir is the working directory of the program, relative to the configuration file.
+	WorkDir string `toml:"workdir" usage:"the working directory of the program (default the main package's directory)"`
+	// Roots are the watched directories, relative to the configuration file.
+	Roots               []string `toml:"roots" usage:"comma separated directories which are watched (default the main package's directory)"`
+	DisableLocalModules bool     `toml:"disable-local-modules" usage:"do not watch the local modules of the go.mod's replace directives and the go.work file"`
+	WatchImportsOnly    bool     `toml:"watch-imports-only" usage:"reload only on changes of the packages which the main package imports and the files they embed"`
+	// Delay is the `rizla.Project#AllowRunAfter`.
+	Delay            time.Duration `toml:"delay" usage:"wait that long after the last change before reload"`
+	AllowReloadAfter time.Duration `toml:"allow-reload-after" usage:"the minimum duration between two reloads (default 2s)"`
+	Debounce         time.Duration `toml:"debounce" usage:"reload when no change is made for that long, the changes are reloaded at once (default 100ms)"`
+	// TriggerOps the names of the `rizla.Project#TriggerOps`, i.e "create" or "write".
+	TriggerOps                []string `toml:"trigger-ops" usage:"comma separated operations of the changes which fire a reload: create, write, remove, rename, chmod (default all but chmod)"`
+	DisableContentHash        bool     `toml:"disable-content-hash" usage:"reload even if the content of the changed files is the same as the last build"`
+	DisableRuntimeDir         bool     `toml:"disable-runtime-dir" usage:"do not watch directories that are created at runtime"`
+	DisableProgramRerunOutput bool     `toml:"disable-program-rerun-output" usage:"hide the program's output after its first run"`
+	// Include glob patterns of the files which fire a reload on change, see `rizla.Project#Include`.
+	Include []string `toml:"include" usage:"comma separated glob patterns of the files which fire a reload, i.e *.go,*.html,web/**/*.tmpl (default *.go)"`
+	// Exclude glob patterns of the files and directories which are ignored, even if they are included.
+	Exclude []string `toml:"exclude" usage:"comma separated glob patterns of the files and directories which are ignored, i.e *_test.go,tmp/"`
+	// ExcludeDirs directory patterns which are not watched, they replace the `rizla.DefaultExclude`.
+	ExcludeDirs      []string `toml:"exclude-dirs" usage:"comma separated directory patterns which are not watched (default .git,node_modules,vendor)"`
+	DisableGitignore bool     `toml:"disable-gitignore" usage:"do not ignore the files and directories which are ignored by the .gitignore files"`
+	// StopSignal is the name of the `rizla.Project#StopSignal`, i.e "SIGTERM" or "INT".
+	StopSignal  string        `toml:"stop-signal" usage:"the signal which stops the program gracefully, i.e SIGTERM or SIGINT (default SIGTERM)"`
+	StopTimeout time.Duration `toml:"stop-timeout" usage:"wait that long for the program to exit after the stop signal, before it's killed (default 5s)"`
+	// Ports the program listens on, they are waited to be released before the program restarts.
+	Ports        []int         `toml:"ports" usage:"comma separated TCP ports which are waited to be released before the program restarts"`
+	DetectPorts  bool          `toml:"detect-ports" usage:"detect the TCP ports which the program listens on and wait for them to be released before it restarts (linux only)"`
+	PortsTimeout time.Duration `toml:"ports-timeout" usage:"the maximum time to wait for the ports to be released (default 10s)"`
+
+	RestartOnCrash bool          `toml:"restart-on-crash" usage:"start the program again when it crashes, with an exponential backoff"`
+	RestartBackoff time.Duration `toml:"restart-backoff" usage:"the delay of the first restart after a crash, it doubles on each consecutive crash (default 500ms)"`
+	MaxRestarts    int           `toml:"max-restarts" usage:"the maximum number of consecutive restarts, a negative value means no limit (default 5)"`
+	// ReadyURL, ReadyStatus, ReadyAddr and ReadyOutput are the `rizla.Project#Ready` check.
+	ReadyURL      string        `toml:"ready-url" usage:"the program is ready when a GET request to this URL responds with the ready-status, i.e http://localhost:8080/health"`
+	ReadyStatus   int           `toml:"ready-status" usage:"the expected status code of the ready-url's response (default any 2xx)"`
+	ReadyAddr     string        `toml:"ready-addr" usage:"the program is ready when this TCP address accepts connections, i.e localhost:8080"`
+	ReadyOutput   string        `toml:"ready-output" usage:"the program is ready when a line of its output matches this regular expression, i.e \"listening on\""`
+	ReadyTimeout  time.Duration `toml:"ready-timeout" usage:"the maximum time to wait for the program to be ready (default 30s)"`
+	ReadyInterval time.Duration `toml:"ready-interval" usage:"the time between two attempts of the ready-url or the ready-addr (default 250ms)"`
+	// BuildBeforeStop builds the project before the running program is stopped,
+	// the running program keeps running if the build fails.
+	BuildBeforeStop bool     `toml:"build-before-stop" usage:"build before the running program is stopped, it keeps running if the build fails"`
+	BuildTags       []string `toml:"build-tags" usage:"comma separated build tags, the go build's -tags flag"`
+	LDFlags         string   `toml:"ldflags" usage:"the go build's -ldflags flag"`
+	GCFlags         string   `toml:"gcflags" usage:"the go build's -gcflags flag"`
+	Race            bool     `toml:"race" usage:"build with the data race detector enabled, the go build's -race flag"`
+	BuildArgs       []string `toml:"build-args" usage:"comma separated extra arguments of the go build"`
+	BuildEnv        []string `toml:"build-env" usage:"comma separated environment variables of the build, i.e CGO_ENABLED=0"`
+	// Output is the path of the executable file, relative to the configuration file.
+	Output string `toml:"output" usage:"the path of the program's executable file (default a file inside a temporary directory)"`
+	// BuildCommand is a custom command which replaces the go build, see `rizla.SplitCommand`.
+	BuildCommand string `toml:"build-command" usage:"a custom command which replaces the go build, it should write the executable file to the $RIZLA_OUTPUT"`
+	// BuildDir is the working directory of the build-command, relative to the configuration file.
+	BuildDir string `toml:"build-dir" usage:"the working directory of the build-command (default the main package's directory)"`
+	// Stages are the [[project.stage]] entries, the commands which run before each build.
+	Stages []stageConfig `toml:"stage"`
+	// OnReloadHooks are the [[project.onreload-hook]] entries, the commands which run before each reload.
+	OnReloadHooks []hookConfig `toml:"onreload-hook"`
+	// OnReloadedHooks are the [[project.onreloaded-hook]] entries, the commands which run after each reload.
+	OnReloadedHooks []hookConfig `toml:"onreloaded-hook"`
+}
+
+// hookConfig is the representation of a hook entry of a project, see `rizla.Hook`.
+type hookConfig struct {
+	Command string `toml:"command"`
+	// Policy is the name of the `rizla.Hook#Policy`, i.e "abort", "continue" or "exit".
+	Policy  string        `toml:"policy"`
+	Timeout time.Duration `toml:"timeout"`
+}
+
+// hook returns the rizla hook of the configuration.
+func (hc hookConfig) hook() (rizla.Hook, error) {
+	h := rizla.Hook{Command: hc.Command, Timeout: hc.Timeout}
+
+	args, err := rizla.SplitCommand(hc.Command)
+	if err != nil {
+		return h, err
+	}
+	if len(args) == 0 {
+		return h, fmt.Errorf("hook: empty command")
+	}
+
+	if hc.Timeout < 0 {
+		return h, fmt.Errorf("hook %s: invalid timeout %s", hc.Command, hc.Timeout)
+	}
+
+	if hc.Policy != "" {
+		if h.Policy, err = rizla.ParseHookPolicy(hc.Policy); err != nil {
+			return h, fmt.Errorf("hook %s: %v", hc.Command, err)
+		}
+	}
+
+	return h, nil
+}
+
+// stageConfig is the representation of a [[project.stage]] entry of the `ConfigFilename` file,
+// see `rizla.Stage`.
+type stageConfig struct {
+	Name string `toml:"name"`
+	// Command is the stage's command, i.e "go generate ./...", see `rizla.SplitCommand`.
+	Command string `toml:"command"`
+	// Dir is the working directory of the command, relative to the configuration file.
+	Dir      string        `toml:"dir"`
+	Triggers []string      `toml:"triggers"`
+	Timeout  time.Duration `toml:"timeout"`
+}
+
+// validate reports whether the configuration has invalid values.
+func (c *config) validate() error {
+	if c.Watcher != "" {
+		if _, ok := rizla.WatcherFromFlag(c.Watcher); !ok {
+			return fmt.Errorf("unknown watcher %q, expected \"signal\" or \"walk\"", c.Watcher)
+		}
+	}
+
+	if c.Interval < 0 {
+		return fmt.Errorf("invalid interval %s", c.Interval)
+	}
+
+	if c.HookPolicy != "" {
+		if _, err := rizla.ParseHookPolicy(c.HookPolicy); err != nil {
+			return err
+		}
+	}
+
+	if c.HookTimeout < 0 {
+		return fmt.Errorf("invalid hook timeout %s", c.HookTimeout)
+	}
+
+	for _, command := range append(append([]string(nil), c.OnReload...), c.OnReloaded...) {
+		if _, err := (hookConfig{Command: command}).hook(); err != nil {
+			return err
+		}
+	}
+
+	for _, pc := range c.Projects {
+		for _, env := range pc.BuildEnv {
+			if !strings.Contains(env, "=") {
+				return fmt.Errorf("invalid build environment variable %q, expected key=value", env)
+			}
+		}
+
+		for _, port := range pc.Ports {
+			if port <= 0 || port > 65535 {
+				return fmt.Errorf("invalid port %d", port)
+			}
+		}
+
+		if pc.StopSignal != "" {
+			if _, err := parseSignal(pc.StopSignal); err != nil {
+				return err
+			}
+		}
+
+		if pc.RestartBackoff < 0 {
+			return fmt.Errorf("invalid restart backoff %s", pc.RestartBackoff)
+		}
+
+		if err := pc.validateReady(); err != nil {
+			return err
+		}
+
+		if _, err := parseOps(pc.TriggerOps); err != nil {
+			return err
+		}
+
+		for _, patterns := range [][]string{pc.Include, pc.Exclude, pc.ExcludeDirs} {
+			if err := validatePatterns(patterns); err != nil {
+				return err
+			}
+		}
+
+		if _, err := rizla.SplitCommand(pc.BuildCommand); err != nil {
+			return fmt.Errorf("build command: %v", err)
+		}
+		if pc.BuildDir != "" && pc.BuildCommand == "" {
+			return fmt.Errorf("build-dir %s: no build-command", pc.BuildDir)
+		}
+
+		for _, hc := range append(append([]hookConfig(nil), pc.OnReloadHooks...), pc.OnReloadedHooks...) {
+			if _, err := hc.hook(); err != nil {
+				return err
+			}
+		}
+
+		for _, sc := range pc.Stages {
+			if args, err := rizla.SplitCommand(sc.Command); err != nil {
+				return fmt.Errorf("stage %q: %v", sc.Name, err)
+			} else if len(args) == 0 {
+				return fmt.Errorf("stage %q: empty command", sc.Name)
+			}
+
+			if sc.Timeout < 0 {
+				return fmt.Errorf("stage %q: invalid timeout %s", sc.Name, sc.Timeout)
+			}
+
+			if err := validatePatterns(sc.Triggers); err != nil {
+				return fmt.Errorf("stage %q: %v", sc.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// loadConfig reads and parses the configuration file.
+func loadConfig(filename string) (*config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(config)
+	md, err := toml.Decode(string(data), c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("%s: unknown key %q", filename, undecoded[0].String())
+	}
+
+	if len(c.Projects) == 0 {
+		return nil, fmt.Errorf("%s: no [[project]] found", filename)
+	}
+
+	// main files are relative to the configuration file.
+	dir, _ := filepath.Abs(filepath.Dir(filename))
+	for i := range c.Projects {
+		pc := &c.Projects[i]
+		if pc.Main == "" {
+			pc.Main = "main.go"
+		}
+		if strings.HasSuffix(pc.Main, ".go") || strings.HasPrefix(pc.Main, ".") {
+			pc.Main = relativeTo(dir, pc.Main)
+		}
+		pc.Output = relativeTo(dir, pc.Output)
+		pc.WorkDir = relativeTo(dir, pc.WorkDir)
+		pc.BuildDir = relativeTo(dir, pc.BuildDir)
+		for j, root := range pc.Roots {
+			pc.Roots[j] = relativeTo(dir, root)
+		}
+		for j := range pc.Stages {
+			pc.Stages[j].Dir = relativeTo(dir, pc.Stages[j].Dir)
+		}
+	}
+
+	if err = c.validate(); err != nil {
+		return nil, fmt.Errorf("%s: %v", filename, err)
+	}
+
+	return c, nil
+}
+
+// withDefaults returns a copy of the configuration where the unset values are replaced
+// by the ones which "run" uses, i.e for the "config print" command.
+func (c *config) withDefaults() *config {
+	d := *c
+	if d.Watcher == "" {
+		d.Watcher = "signal"
+	}
+	if d.Interval <= 0 {
+		d.Interval = rizla.DefaultWalkLoopSleep
+	}
+	if d.HookPolicy == "" {
+		d.HookPolicy = rizla.DefaultHookPolicy.String()
+	}
+	if d.HookTimeout <= 0 {
+		d.HookTimeout = rizla.DefaultHookTimeout
+	}
+
+	defaults := rizla.NewProject("main.go")
+	hooks := func(hcs []hookConfig, abortPolicy string) []hookConfig {
+		hcs = append([]hookConfig(nil), hcs...)
+		for i := range hcs {
+			if hcs[i].Policy == "" {
+				hcs[i].Policy = d.HookPolicy
+			}
+			if strings.EqualFold(hcs[i].Policy, rizla.HookAbort.String()) {
+				hcs[i].Policy = abortPolicy
+			}
+			if hcs[i].Timeout <= 0 {
+				hcs[i].Timeout = d.HookTimeout
+			}
+		}
+		return hcs
+	}
+
+	d.Projects = append([]projectConfig(nil), c.Projects...)
+	for i := range d.Projects {
+		pc := &d.Projects[i]
+		if pc.AllowReloadAfter <= 0 {
+			pc.AllowReloadAfter = defaults.AllowReloadAfter
+		}
+		if pc.Debounce <= 0 {
+			pc.Debounce = rizla.DefaultDebounce
+		}
+		if len(pc.TriggerOps) == 0 {
+			pc.TriggerOps = strings.Split(strings.ToLower(rizla.DefaultTriggerOps.String()), "|")
+		}
+		if len(pc.Include) == 0 {
+			pc.Include = rizla.DefaultInclude
+		}
+		if len(pc.ExcludeDirs) == 0 {
+			for _, dir := range rizla.DefaultExclude {
+				pc.ExcludeDirs = append(pc.ExcludeDirs, strings.TrimSuffix(dir, "/"))
+			}
+		}
+		if pc.StopSignal == "" {
+			pc.StopSignal = "SIGTERM"
+		}
+		if pc.StopTimeout <= 0 {
+			pc.StopTimeout = defaults.StopTimeout
+		}
+		if pc.PortsTimeout <= 0 {
+			pc.PortsTimeout = defaults.PortsTimeout
+		}
+		if pc.RestartBackoff <= 0 {
+			pc.RestartBackoff = rizla.DefaultRestartBackoff
+		}
+		if pc.MaxRestarts == 0 {
+			pc.MaxRestarts = rizla.DefaultMaxRestarts
+		}
+		if pc.ReadyTimeout <= 0 {
+			pc.ReadyTimeout = rizla.DefaultReadyTimeout
+		}
+		if pc.ReadyInterval <= 0 {
+			pc.ReadyInterval = rizla.DefaultReadyInterval
+		}
+
+		pc.Stages = append([]stageConfig(nil), pc.Stages...)
+		for j := range pc.Stages {
+			if pc.Stages[j].Timeout <= 0 {
+				pc.Stages[j].Timeout = rizla.DefaultStageTimeout
+			}
+		}
+		pc.OnReloadHooks = hooks(pc.OnReloadHooks, rizla.HookAbort.String())
+		// the reload is already done, see `rizla.Project#OnReloadedHooks`.
+		pc.OnReloadedHooks = hooks(pc.OnReloadedHooks, rizla.HookContinue.String())
+	}
+
+	return &d
+}
+
+// relativeTo returns the "path" joined to the "dir", if it's not empty or absolute.
+func relativeTo(dir, path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// project returns a new rizla project based on the configuration.
+func (pc projectConfig) project() (*rizla.Project, error) {
+	if strings.HasSuffix(pc.Main, ".go") {
+		if !fileExists(pc.Main) {
+			return nil, fmt.Errorf("file %s does not exists", pc.Main)
+		}
+	} else if _, err := rizla.PackageDir(pc.Main); err != nil {
+		return nil, fmt.Errorf("package %s: %v", pc.Main, err)
+	}
+
+	p := rizla.NewProject(pc.Main, pc.Args...)
+	p.Name = pc.Name
+	p.DisableLocalModules = pc.DisableLocalModules
+	p.WatchImportsOnly = pc.WatchImportsOnly
+	if pc.WorkDir != "" {
+		p.WorkDir, _ = filepath.Abs(pc.WorkDir)
+	}
+	for _, root := range pc.Roots {
+		root, _ = filepath.Abs(root)
+		p.Roots = append(p.Roots, root)
+	}
+	p.AllowRunAfter = pc.Delay
+	if pc.AllowReloadAfter > 0 {
+		p.AllowReloadAfter = pc.AllowReloadAfter
+	}
+	p.Debounce = pc.Debounce
+	ops, err := parseOps(pc.TriggerOps)
+	if err != nil {
+		return nil, err
+	}
+	p.TriggerOps = ops
+	p.DisableContentHash = pc.DisableContentHash
+	p.DisableRuntimeDir = pc.DisableRuntimeDir
+	p.DisableProgramRerunOutput = pc.DisableProgramRerunOutput
+
+	if pc.StopSignal != "" {
+		sig, err := parseSignal(pc.StopSignal)
+		if err != nil {
+			return nil, err
+		}
+		p.StopSignal = sig
+	}
+	if pc.StopTimeout > 0 {
+		p.StopTimeout = pc.StopTimeout
+	}
+
+	p.BuildBeforeStop = pc.BuildBeforeStop
+	p.BuildTags = pc.BuildTags
+	p.LDFlags = pc.LDFlags
+	p.GCFlags = pc.GCFlags
+	p.Race = pc.Race
+	p.BuildArgs = pc.BuildArgs
+	p.BuildEnv = pc.BuildEnv
+	p.Output = pc.Output
+	if p.BuildCommand, err = rizla.SplitCommand(pc.BuildCommand); err != nil {
+		return nil, err
+	}
+	if pc.BuildDir != "" {
+		p.BuildDir, _ = filepath.Abs(pc.BuildDir)
+	}
+	for _, sc := range pc.Stages {
+		command, err := rizla.SplitCommand(sc.Command)
+		if err != nil {
+			return nil, err
+		}
+
+		p.Stages = append(p.Stages, rizla.Stage{
+			Name:     sc.Name,
+			Command:  command,
+			Dir:      sc.Dir,
+			Triggers: sc.Triggers,
+			Timeout:  sc.Timeout,
+		})
+	}
+	for _, hc := range pc.OnReloadHooks {
+		h, err := hc.hook()
+		if err != nil {
+			return nil, err
+		}
+		p.OnReloadHooks = append(p.OnReloadHooks, h)
+	}
+	for _, hc := range pc.OnReloadedHooks {
+		h, err := hc.hook()
+		if err != nil {
+			return nil, err
+		}
+		p.OnReloadedHooks = append(p.OnReloadedHooks, h)
+	}
+	p.Ports = pc.Ports
+	p.DetectPorts = pc.DetectPorts
+	if pc.PortsTimeout > 0 {
+		p.PortsTimeout = pc.PortsTimeout
+	}
+
+	p.RestartOnCrash = pc.RestartOnCrash
+	p.RestartBackoff = pc.RestartBackoff
+	p.MaxRestarts = pc.MaxRestarts
+
+	p.Ready = pc.ready()
+
+	p.Include = pc.Include
+	if len(pc.Exclude) > 0 || len(pc.ExcludeDirs) > 0 {
+		dirs := rizla.DefaultExclude
+		if len(pc.ExcludeDirs) > 0 {
+			dirs = nil
+			for _, dir := range pc.ExcludeDirs {
+				dirs = append(dirs, strings.TrimSuffix(dir, "/")+"/")
+			}
+		}
+		p.Exclude = append(append([]string(nil), dirs...), pc.Exclude...)
+	}
+	p.DisableGitignore = pc.DisableGitignore
+
+	return p, nil
+}
+
+// signals are the signals which can be used as a stop signal.
+var signals = map[string]os.Signal{
+	"SIGHUP":  syscall.SIGHUP,
+	"SIGINT":  syscall.SIGINT,
+	"SIGQUIT": syscall.SIGQUIT,
+	"SIGKILL": syscall.SIGKILL,
+	"SIGTERM": syscall.SIGTERM,
+}
+
+// validateReady reports whether the ready check of the project is invalid.
+func (pc projectConfig) validateReady() error {
+	if pc.ReadyURL != "" {
+		u, err := url.Parse(pc.ReadyURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid ready URL %q, expected an http or https URL", pc.ReadyURL)
+		}
+	}
+
+	if pc.ReadyStatus != 0 && (pc.ReadyStatus < 100 || pc.ReadyStatus > 599) {
+		return fmt.Errorf("invalid ready status %d", pc.ReadyStatus)
+	}
+
+	if pc.ReadyAddr != "" {
+		if _, _, err := net.SplitHostPort(pc.ReadyAddr); err != nil {
+			return fmt.Errorf("invalid ready address %q: %v", pc.ReadyAddr, err)
+		}
+	}
+
+	if _, err := regexp.Compile(pc.ReadyOutput); err != nil {
+		return fmt.Errorf("invalid ready output: %v", err)
+	}
+
+	if pc.ReadyTimeout < 0 || pc.ReadyInterval < 0 {
+		return fmt.Errorf("invalid ready timeout %s or interval %s", pc.ReadyTimeout, pc.ReadyInterval)
+	}
+
+	return nil
+}
+
+// ready returns the ready check of the project, nil if none of the ready-url, ready-addr and ready-output is set.
+func (pc projectConfig) ready() *rizla.ReadyCheck {
+	if pc.ReadyURL == "" && pc.ReadyAddr == "" && pc.ReadyOutput == "" {
+		return nil
+	}
+
+	check := &rizla.ReadyCheck{
+		URL:      pc.ReadyURL,
+		Status:   pc.ReadyStatus,
+		Addr:     pc.ReadyAddr,
+		Timeout:  pc.ReadyTimeout,
+		Interval: pc.ReadyInterval,
+	}
+	if pc.ReadyOutput != "" {
+		// validated by the validateReady.
+		check.Output = regexp.MustCompile(pc.ReadyOutput)
+	}
+	return check
+}
+
+// parseSignal returns the signal of a name, the "SIG" prefix is optional, i.e "SIGTERM" or "term".
+func parseSignal(name string) (os.Signal, error) {
+	key := strings.ToUpper(name)
+	if !strings.HasPrefix(key, "SIG") {
+		key = "SIG" + key
+	}
+
+	sig, ok := signals[key]
+	if !ok {
+		return nil, fmt.Errorf("unknown signal %q, expected one of SIGHUP, SIGINT, SIGQUIT, SIGKILL or SIGTERM", name)
+	}
+	return sig, nil
+}
+
+// parseOps returns the operations of the names, zero if "names" is empty.
+func parseOps(names []string) (rizla.Op, error) {
+	var ops rizla.Op
+	for _, name := range names {
+		op, err := rizla.ParseOp(name)
+		if err != nil {
+			return 0, err
+		}
+		ops |= op
+	}
+	return ops, nil
+}
+
+func validatePatterns(patterns []string) error {
+	for _, pattern := range patterns {
+		if !rizla.ValidPattern(pattern) {
+			return fmt.Errorf("invalid pattern %q", pattern)
+		}
+	}
+	return nil
+}
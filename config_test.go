@@ -0,0 +1,344 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/kataras/rizla/rizla"
+)
+
+const testConfig = `
+# rizla configuration
+watcher = "walk"
+onreload = ["./on_reload.sh", 'echo "# not a comment"']
+onreloaded = ["./warm_cache.sh"]
+hook-policy = "continue"
+
+[[project]]
+name = "api" # inline comment
+main = "./cmd/api/main.go"
+args = [
+	"-port", "8080",
+]
+allow-reload-after = "3s"
+include = ["*.go", "*.html"]
+exclude = ["*_test.go"]
+exclude-dirs = [".git", "vendor"]
+restart-on-crash = true
+max-restarts = -1
+build-command = "make build"
+build-dir = "."
+
+[[project.stage]]
+name = "generate"
+command = "go generate ./..."
+dir = "."
+triggers = ["*.templ"]
+timeout = "1m"
+
+[[project.onreload-hook]]
+command = "./notify.sh 'the api'"
+policy = "exit"
+
+[[project.onreloaded-hook]]
+command = "curl -fsS http://localhost:8080"
+timeout = "5s"
+
+[[project]]
+main = "/worker/main.go"
+disable-runtime-dir = true
+ready-output = "^\\[server" # brackets inside a string
+stop-signal = "INT"
+`
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, ConfigFilename)
+	if err := os.WriteFile(filename, []byte(testConfig), os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &config{
+		Watcher:    "walk",
+		OnReload:   []string{"./on_reload.sh", `echo "# not a comment"`},
+		OnReloaded: []string{"./warm_cache.sh"},
+		HookPolicy: "continue",
+		Projects: []projectConfig{
+			{
+				Name:             "api",
+				Main:             filepath.Join(dir, "cmd", "api", "main.go"),
+				Args:             []string{"-port", "8080"},
+				AllowReloadAfter: 3 * time.Second,
+				Include:          []string{"*.go", "*.html"},
+				Exclude:          []string{"*_test.go"},
+				ExcludeDirs:      []string{".git", "vendor"},
+				RestartOnCrash:   true,
+				MaxRestarts:      -1,
+				BuildCommand:     "make build",
+				BuildDir:         dir,
+				Stages: []stageConfig{
+					{Name: "generate", Command: "go generate ./...", Dir: dir, Triggers: []string{"*.templ"}, Timeout: time.Minute},
+				},
+				OnReloadHooks: []hookConfig{
+					{Command: "./notify.sh 'the api'", Policy: "exit"},
+				},
+				OnReloadedHooks: []hookConfig{
+					{Command: "curl -fsS http://localhost:8080", Timeout: 5 * time.Second},
+				},
+			},
+			{
+				Main:              "/worker/main.go",
+				DisableRuntimeDir: true,
+				ReadyOutput:       `^\[server`,
+				StopSignal:        "INT",
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(expected, c) {
+		t.Fatalf("expected config:\n%#v\nbut got:\n%#v", expected, c)
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	tests := map[string]string{
+		"unknown key":       "[[project]]\nmain = \"main.go\"\nunknown = 1",
+		"invalid duration":  "[[project]]\ndelay = \"5\"",
+		"type mismatch":     "[[project]]\nargs = \"-port\"",
+		"no projects":       "watcher = \"walk\"",
+		"unterminated":      "watcher = \"walk",
+		"empty stage":       "[[project]]\n[[project.stage]]\nname = \"vet\"",
+		"hook policy":       "hook-policy = \"ignore\"\n[[project]]",
+		"unterminated hook": "[[project]]\n[[project.onreload-hook]]\ncommand = \"echo 'a\"",
+		"ready url":         "[[project]]\nready-url = \"localhost:8080\"",
+		"ready output":      "[[project]]\nready-output = \"(\"",
+		"restart backoff":   "[[project]]\nrestart-backoff = \"-1s\"",
+		"unknown table key": "[[project]]\n[[project.stage]]\ncommand = \"go vet\"\nunknown = 1",
+		"build dir":         "[[project]]\nbuild-dir = \".\"",
+	}
+
+	dir := t.TempDir()
+	for name, contents := range tests {
+		filename := filepath.Join(dir, ConfigFilename)
+		if err := os.WriteFile(filename, []byte(contents), os.FileMode(0644)); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := loadConfig(filename); err == nil {
+			t.Fatalf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestProjectPatterns(t *testing.T) {
+	root := t.TempDir()
+	mainFile := filepath.Join(root, "main.go")
+	if err := os.WriteFile(mainFile, []byte("package main\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	pc := projectConfig{
+		Main:        mainFile,
+		Include:     []string{"*.go", "views/**/*.html"},
+		Exclude:     []string{"*_test.go"},
+		ExcludeDirs: []string{"tmp"},
+	}
+	p, err := pc.project()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]bool{
+		"main.go":                      true,
+		"views/index.html":             true,
+		"views/partials/footer.html":   true,
+		"index.html":                   false,
+		"main_test.go":                 false,
+		".goutputstream-ABCD":          false,
+		"assets/style.css":             false,
+		"tmp/main.go":                  false,
+		"vendor/example.com/x/main.go": true, // exclude-dirs replaces the default ones.
+	}
+
+	for name, expected := range files {
+		if got := p.Matcher(filepath.Join(root, filepath.FromSlash(name))); got != expected {
+			t.Fatalf("expected %v but got %v for %s", expected, got, name)
+		}
+	}
+
+	if p.Watcher(filepath.Join(root, "tmp")) {
+		t.Fatalf("expected the tmp directory to not be watched")
+	}
+}
+
+func TestProjectReady(t *testing.T) {
+	mainFile, _ := filepath.Abs("main.go")
+
+	p, err := projectConfig{Main: mainFile}.project()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.Ready != nil {
+		t.Fatal("expected no ready check by default")
+	}
+
+	pc := projectConfig{Main: mainFile, ReadyAddr: "localhost:8080", ReadyOutput: "listening on", ReadyTimeout: time.Minute}
+	if p, err = pc.project(); err != nil {
+		t.Fatal(err)
+	}
+	if p.Ready == nil || p.Ready.Addr != pc.ReadyAddr || !p.Ready.Output.MatchString("server listening on :8080") || p.Ready.Timeout != time.Minute {
+		t.Fatalf("unexpected ready check %#v", p.Ready)
+	}
+}
+
+func TestParseRun(t *testing.T) {
+	mainFile, _ := filepath.Abs("main.go")
+
+	c, err := parseRun("run", []string{"-walk", "-delay=5s", "-include", "*.go,*.html", "-include=*.tmpl", "main.go", "--", "-host", "myhost.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &config{
+		Watcher: "walk",
+		Projects: []projectConfig{
+			{
+				Main:    mainFile,
+				Args:    []string{"-host", "myhost.com"},
+				Delay:   5 * time.Second,
+				Include: []string{"*.go", "*.html", "*.tmpl"},
+			},
+		},
+	}
+
+	if !reflect.DeepEqual(expected, c) {
+		t.Fatalf("expected config:\n%#v\nbut got:\n%#v", expected, c)
+	}
+
+	// legacy, program arguments without the "--" separator.
+	if c, err = parseRun("run", []string{"main.go", "-host", "myhost.com"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, got := []string{"-host", "myhost.com"}, c.Projects[0].Args; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected program arguments %v but got %v", expected, got)
+	}
+
+	// legacy, the watchers' flags and the watcher as the first argument.
+	watchers := []struct {
+		args    []string
+		watcher string
+	}{
+		{[]string{"-w", "main.go"}, "walk"},
+		{[]string{"-s", "main.go"}, "signal"},
+		{[]string{"walk", "main.go"}, "walk"},
+		{[]string{"-walk", "signal", "main.go"}, "signal"},
+	}
+
+	for _, tt := range watchers {
+		if c, err = parseRun("run", tt.args); err != nil {
+			t.Fatal(err)
+		}
+
+		if c.Watcher != tt.watcher {
+			t.Fatalf("expected watcher %q for arguments %v but got %q", tt.watcher, tt.args, c.Watcher)
+		}
+		if len(c.Projects) != 1 || c.Projects[0].Main != mainFile {
+			t.Fatalf("expected the main.go project for arguments %v but got:\n%#v", tt.args, c.Projects)
+		}
+	}
+
+	invalid := [][]string{
+		{"-delay=5", "main.go"},
+		{"-delayed=5s", "main.go"},
+		{"-watcher=poll", "main.go"},
+		{"--", "-host"},
+		{"-config", "does_not_exist.toml"},
+	}
+
+	for _, args := range invalid {
+		if _, err = parseRun("run", args); err == nil {
+			t.Fatalf("expected an error for arguments %v", args)
+		}
+	}
+}
+
+func TestConfigWithDefaults(t *testing.T) {
+	c, err := parseRun("config print", []string{"-debounce=1s", "main.go"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Projects[0].OnReloadedHooks = []hookConfig{{Command: "./warm_cache.sh"}}
+
+	d := c.withDefaults()
+	if pc := d.Projects[0]; d.HookPolicy != "abort" || pc.AllowReloadAfter != 2*time.Second ||
+		pc.Debounce != time.Second || pc.MaxRestarts != rizla.DefaultMaxRestarts || pc.OnReloadedHooks[0].Policy != "continue" {
+		t.Fatalf("unexpected defaults:\n%#v", d)
+	}
+	if c.HookPolicy != "" || c.Projects[0].OnReloadedHooks[0].Policy != "" {
+		t.Fatal("expected the configuration to not be modified")
+	}
+
+	// the printed configuration is a valid configuration file.
+	var buf bytes.Buffer
+	if err = toml.NewEncoder(&buf).Encode(d); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), ConfigFilename)
+	if err = os.WriteFile(filename, buf.Bytes(), os.FileMode(0644)); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := loadConfig(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(d, loaded) {
+		t.Fatalf("expected config:\n%#v\nbut got:\n%#v", d, loaded)
+	}
+}
+
+func TestParseTargets(t *testing.T) {
+	dir, _ := filepath.Abs("rizla")
+	mainFile, _ := filepath.Abs("main.go")
+
+	c, err := parseTargets([]string{"rizla", "example.com/svc/cmd/worker", "main.go", "--", "-port", "8080"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []projectConfig{
+		{Main: dir},
+		{Main: "example.com/svc/cmd/worker"},
+		{Main: mainFile, Args: []string{"-port", "8080"}},
+	}
+
+	if !reflect.DeepEqual(expected, c.Projects) {
+		t.Fatalf("expected projects:\n%#v\nbut got:\n%#v", expected, c.Projects)
+	}
+
+	// a directory after a *.go file is a program argument.
+	if c, err = parseTargets([]string{"main.go", "rizla", "example.com/data"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected = []projectConfig{
+		{Main: mainFile, Args: []string{"rizla", "example.com/data"}},
+	}
+
+	if !reflect.DeepEqual(expected, c.Projects) {
+		t.Fatalf("expected projects:\n%#v\nbut got:\n%#v", expected, c.Projects)
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// stringsValue is a flag.Value which accepts comma separated values,
+// the flag can be given more than once.
+type stringsValue struct {
+	values *[]string
+}
+
+var _ flag.Value = stringsValue{}
+
+func (v stringsValue) String() string {
+	if v.values == nil {
+		return ""
+	}
+	return strings.Join(*v.values, ",")
+}
+
+func (v stringsValue) Set(s string) error {
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			*v.values = append(*v.values, value)
+		}
+	}
+	return nil
+}
+
+// intsValue is a flag.Value which accepts comma separated integers,
+// the flag can be given more than once.
+type intsValue struct {
+	values *[]int
+}
+
+var _ flag.Value = intsValue{}
+
+func (v intsValue) String() string {
+	if v.values == nil {
+		return ""
+	}
+
+	values := make([]string, len(*v.values))
+	for i, n := range *v.values {
+		values[i] = strconv.Itoa(n)
+	}
+	return strings.Join(values, ",")
+}
+
+func (v intsValue) Set(s string) error {
+	for _, value := range strings.Split(s, ",") {
+		if value = strings.TrimSpace(value); value == "" {
+			continue
+		}
+
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*v.values = append(*v.values, n)
+	}
+	return nil
+}
+
+// bindFlags registers a flag for each field of the struct pointer "v"
+// which has a `toml` and a `usage` tag, the flag's name is the `toml` tag
+// so the command line and the configuration file share the same names.
+func bindFlags(fs *flag.FlagSet, v interface{}) {
+	value := reflect.ValueOf(v).Elem()
+	typ := value.Type()
+
+	for i := 0; i < typ.NumField(); i++ {
+		name, usage := typ.Field(i).Tag.Get("toml"), typ.Field(i).Tag.Get("usage")
+		if name == "" || usage == "" {
+			continue
+		}
+
+		field := value.Field(i)
+		switch ptr := field.Addr().Interface().(type) {
+		case *string:
+			fs.StringVar(ptr, name, *ptr, usage)
+		case *bool:
+			fs.BoolVar(ptr, name, *ptr, usage)
+		case *int:
+			fs.IntVar(ptr, name, *ptr, usage)
+		case *time.Duration:
+			fs.DurationVar(ptr, name, *ptr, usage)
+		case *[]string:
+			fs.Var(stringsValue{ptr}, name, usage)
+		case *[]int:
+			fs.Var(intsValue{ptr}, name, usage)
+		default:
+			panic(fmt.Sprintf("rizla: flag %s: unsupported type %s", name, field.Type()))
+		}
+	}
+}
+
+// overlayFlags copies the fields of "src" to "dst", both are pointers of the same struct type,
+// which their flags, registered by `bindFlags`, were set at the command line.
+func overlayFlags(fs *flag.FlagSet, src, dst interface{}) {
+	srcValue, dstValue := reflect.ValueOf(src).Elem(), reflect.ValueOf(dst).Elem()
+	typ := srcValue.Type()
+
+	fs.Visit(func(f *flag.Flag) {
+		for i := 0; i < typ.NumField(); i++ {
+			if typ.Field(i).Tag.Get("toml") == f.Name && typ.Field(i).Tag.Get("usage") != "" {
+				dstValue.Field(i).Set(srcValue.Field(i))
+				return
+			}
+		}
+	})
+}
@@ -1,18 +1,29 @@
 //Package main rizla builds, runs and monitors your Go Applications with ease.
 //
 //   rizla main.go
-//   rizla C:/myprojects/project1/main.go C:/myprojects/project2/main.go C:/myprojects/project3/main.go
-//   rizla -walk main.go [if -walk then rizla uses the stdlib's filepath.Walk method instead of file system's signals]
+//   rizla run C:/myprojects/project1/main.go C:/myprojects/project2/main.go C:/myprojects/project3/main.go
+//   rizla run -walk main.go [if -walk then rizla uses the stdlib's filepath.Walk method instead of file system's signals]
+//   rizla run -delay=5s main.go -- -host myhost.com -port 1193
+//   rizla [reads the projects from the rizla.toml file of the working directory]
+//   rizla init
+//   rizla config print
+//   rizla version
 //
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
-	"time"
+	"syscall"
 
+	"github.com/BurntSushi/toml"
 	"github.com/kataras/golog"
 	"github.com/kataras/rizla/rizla"
 )
@@ -26,150 +37,444 @@ const (
 	Description = "Rizla builds, runs and monitors your Go Applications with ease."
 )
 
-const delayArgName = "-delay"
+var helpTmpl = fmt.Sprintf(`NAME:
+   %s - %s
+
+USAGE:
+   rizla run [flags] [main.go | ./cmd/app | example.com/app...] [-- program arguments]
+   rizla main.go [program arguments] [same as rizla run main.go -- program arguments]
+      [the arguments after a *.go file which are not *.go files are passed to its program, give the packages before it]
+   rizla [if no *.go file or package is given then the projects are loaded from the %s file of the working directory]
+
+COMMANDS:
+   run            builds, runs and monitors the projects
+   init           creates a %s file in the working directory
+   config print   prints the configuration which "run" would use
+   version        prints the version of rizla
+   help           shows this message
+
+EXAMPLES:
+   rizla run main.go
+   rizla run C:/myprojects/project1/main.go C:/myprojects/project2/main.go C:/myprojects/project3/main.go
+   rizla run -walk main.go [if -walk then rizla uses the stdlib's filepath.Walk method instead of file system's signals]
+   rizla run -walk -interval=500ms main.go [the walk watcher compares the files every "interval"]
+   rizla run -delay=5s main.go [if delay > 0 then the reload happens when no change is made for "delay", all of the changes are reloaded at once]
+   rizla run -onreload="service supervisor restart" main.go or rizla run -onreload="cmd /C echo Hello World!" main.go
+   rizla run -onreloaded="curl -fsS http://localhost:8080/health" main.go [runs after each reload, when the program has started]
+   rizla run -ready-url=http://localhost:8080/health -onreloaded="./notify.sh" main.go [the reload is finished when the program responds]
+   rizla run main.go -- -host myhost.com -port 1193
+   rizla run -restart-on-crash -max-restarts=10 main.go [the program starts again when it crashes, with an exponential backoff]
+   rizla run -roots=. ./cmd/api ./cmd/worker [builds the packages but watches the whole module]
 
-func getDelayFromArg(arg string) (time.Duration, bool) {
-	if strings.HasPrefix(arg, delayArgName) || strings.HasPrefix(arg, delayArgName[1:]) {
-		// [-]delay=5s
-		// [-]delay 5s
+VERSION:
+   %s
 
-		if equalIdx := strings.IndexRune(arg, '='); equalIdx > 0 {
-			delayStr := arg[equalIdx+1:]
-			d, _ := time.ParseDuration(delayStr)
-			return d, true
+FLAGS OF RUN AND CONFIG PRINT:
+`, Name, Description, ConfigFilename, ConfigFilename, Version)
+
+var errorf = golog.New().SetOutput(os.Stderr).Errorf
+
+// commands are the names of the rizla's commands, "run" is the default one.
+var commands = map[string]bool{
+	"run":     true,
+	"init":    true,
+	"config":  true,
+	"version": true,
+	"help":    true,
+}
+
+func main() {
+	args := os.Args[1:]
+
+	cmd := "run"
+	if len(args) > 0 && commands[args[0]] {
+		cmd, args = args[0], args[1:]
+	}
+
+	var err error
+	switch cmd {
+	case "run":
+		err = runCommand(args)
+	case "init":
+		err = initCommand(args)
+	case "config":
+		if len(args) == 0 || args[0] != "print" {
+			err = usageError(`expected "rizla config print"`)
+			break
 		}
+		err = configPrintCommand(args[1:])
+	case "version":
+		fmt.Printf("%s %s\n", strings.ToLower(Name), Version)
+	case "help":
+		help(os.Stdout)
+	default:
+		err = usageError(fmt.Sprintf("unknown command %q", cmd))
+	}
 
-		if spaceIdx := strings.IndexRune(arg, ' '); spaceIdx > 0 {
-			delayStr := arg[spaceIdx+1:]
-			d, _ := time.ParseDuration(delayStr)
-			return d, true
+	if err != nil {
+		if err == flag.ErrHelp {
+			return
 		}
 
+		errorf("%v", err)
+		if _, ok := err.(usageError); ok {
+			fmt.Fprintf(os.Stderr, "run \"rizla help\" for usage.\n")
+			os.Exit(2)
+		}
+		os.Exit(1)
 	}
+}
 
-	return 0, false
+// usageError is an error which caused by invalid command line arguments.
+type usageError string
+
+func (err usageError) Error() string {
+	return string(err)
 }
 
-const onReloadArg = "-onreload"
+func help(w io.Writer) {
+	io.WriteString(w, helpTmpl)
+	fs, _, _ := newRunFlagSet("run")
+	fs.SetOutput(w)
+	fs.PrintDefaults()
+}
 
-func getOnReloadArg(arg string) (string, bool) {
-	if strings.HasPrefix(arg, onReloadArg) || strings.HasPrefix(arg, onReloadArg[1:]) {
-		// first equality ofc...
-		// [-]onreload=cmd1,cmd2,file.sh
-		// [-]onreload cmd1,cmd2,file.sh
+// runFlags contains the values of the flags of the "run" and "config print" commands.
+type runFlags struct {
+	configFile string
+	config     config
+	project    projectConfig
+}
 
-		if equalIdx := strings.IndexRune(arg, '='); equalIdx > 0 {
-			src := arg[equalIdx+1:]
-			return src, true
+// newRunFlagSet returns the flags of the "run" and "config print" commands.
+func newRunFlagSet(name string) (*flag.FlagSet, *runFlags, func(*config)) {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+
+	f := new(runFlags)
+	fs.StringVar(&f.configFile, "config", ConfigFilename, "the configuration file, used when no *.go files or packages are given")
+	for alias, watcher := range watcherFlags {
+		usage := "same as -watcher=" + watcher
+		if watcher == "walk" {
+			usage += ", use the stdlib's filepath.Walk method instead of file system's signals"
 		}
-
-		if spaceIdx := strings.IndexRune(arg, ' '); spaceIdx > 0 {
-			src := arg[spaceIdx+1:]
-			return src, true
+		fs.BoolFunc(alias, usage, func(string) error { return nil })
+	}
+	bindFlags(fs, &f.config)
+	bindFlags(fs, &f.project)
+
+	// apply overrides the configuration's values with the flags which were set at the command line.
+	apply := func(c *config) {
+		overlayFlags(fs, &f.config, c)
+		for alias, watcher := range watcherFlags {
+			if isFlagSet(fs, alias) {
+				c.Watcher = watcher
+			}
 		}
 
+		for i := range c.Projects {
+			overlayFlags(fs, &f.project, &c.Projects[i])
+		}
 	}
 
-	return "", false
+	return fs, f, apply
 }
 
-var helpTmpl = fmt.Sprintf(`NAME:
-   %s - %s
+// watcherFlags are the legacy flags of the watchers, i.e rizla -w main.go, see `rizla.WatcherFromFlag`.
+var watcherFlags = map[string]string{
+	"w":      "walk",
+	"walk":   "walk",
+	"s":      "signal",
+	"signal": "signal",
+}
 
-USAGE:
-   rizla main.go
-   rizla C:/myprojects/project1/main.go C:/myprojects/project2/main.go C:/myprojects/project3/main.go
-   rizla -walk main.go [if -walk then rizla uses the stdlib's filepath.Walk method instead of file system's signals]
-   rizla -delay=5s main.go [if delay > 0 then it delays the reload, also note that it accepts the first change but the rest of changes every "delay"]
-   rizla -onreload="service supervisor restart" main.go or rizla -onreload="cmd /C echo Hello World!" main.go
-VERSION:
-   %s
-   `, Name, Description, Version)
+func isFlagSet(fs *flag.FlagSet, name string) (set bool) {
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return
+}
 
-func main() {
-	argsLen := len(os.Args)
+// parseRun parses the command line arguments of the "run" and "config print" commands
+// and returns the configuration, from the given *.go files and packages or from the configuration file,
+// with the flags applied on it.
+func parseRun(name string, args []string) (*config, error) {
+	fs, f, apply := newRunFlagSet(name)
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			help(os.Stdout)
+			return nil, err
+		}
+		return nil, usageError(err.Error())
+	}
 
-	errorf := golog.New().SetOutput(os.Stderr).Errorf
+	targets := fs.Args()
+	// legacy, the watcher as the first argument, i.e rizla walk main.go.
+	legacyWatcher := ""
+	if len(targets) > 0 && !isDirectory(targets[0]) {
+		switch targets[0] {
+		case "walk", "signal":
+			legacyWatcher = targets[0]
+		case "default":
+			legacyWatcher = "signal"
+		}
 
-	if argsLen <= 1 {
-		help(-1)
-	} else if isArgHelp(os.Args[1]) {
-		help(0)
+		if legacyWatcher != "" {
+			targets = targets[1:]
+		}
 	}
 
-	args := os.Args[1:]
-	programFiles := make(map[string][]string, 0) // key = main file, value = args.
-	fsWatcher, _ := rizla.WatcherFromFlag("signal")
+	c, err := parseTargets(targets)
+	if err != nil {
+		return nil, err
+	}
 
-	var lastProgramFile string
-	var delayOnDetect time.Duration
+	if len(c.Projects) == 0 {
+		// no program files given, read them from the configuration file.
+		if !fileExists(f.configFile) {
+			return nil, usageError(fmt.Sprintf("please provide a *.go file, a package or a %s file", f.configFile))
+		}
 
-	for i, a := range args {
-		// if main files with arguments aren't passed yet,
-		// then the argument(s) should refer to the rizla tool and not the
-		// external programs.
-		if lastProgramFile == "" {
-			// The first argument must be the method type of the file system's watcher.
-			// if -w,-walk,walk then
-			//   asks to use the stdlib's filepath.walk method instead of the operating system's signal.
-			//   It's only usage is when the user's IDE overrides the os' signals.
-			// otherwise
-			//   use the fsnotify's operating system's file system's signals.
-			if watcher, ok := rizla.WatcherFromFlag(a); ok {
-				fsWatcher = watcher
-				continue
-			}
+		if c, err = loadConfig(f.configFile); err != nil {
+			return nil, err
+		}
+	}
 
-			if delay, ok := getDelayFromArg(a); ok {
-				delayOnDetect = delay
-				continue
-			}
+	apply(c)
+	if legacyWatcher != "" {
+		c.Watcher = legacyWatcher
+	}
+	if c.Watcher == "" {
+		c.Watcher = "signal"
+	}
+	return c, c.validate()
+}
+
+// parseTargets parses the positional arguments,
+// which are the main *.go files or packages followed by their program's arguments, if any.
+// The "--" separates the last *.go file or package from its program's arguments,
+// after a *.go file the arguments which are not *.go files are its program's arguments too, i.e rizla main.go ./data.
+func parseTargets(args []string) (*config, error) {
+	c := new(config)
+	fileGiven := false
+
+	for i, a := range args {
+		last := len(c.Projects) - 1
 
-			if onReloadSources, ok := getOnReloadArg(a); ok {
-				rizla.OnReloadScripts = strings.Split(onReloadSources, ",")
+		if a == "--" {
+			if last == -1 {
+				return nil, usageError("program arguments given but no *.go file or package")
 			}
+			c.Projects[last].Args = append(c.Projects[last].Args, args[i+1:]...)
+			break
 		}
 
 		// it's main.go or any go main program
 		if strings.HasSuffix(a, ".go") {
-			programFiles[a] = []string{}
-			lastProgramFile = a
+			// the argument is a *.go file but doesn't exists on user's disk
+			mainFile, _ := filepath.Abs(a)
+			if !fileExists(mainFile) {
+				return nil, fmt.Errorf("file %s does not exists", mainFile)
+			}
+
+			c.Projects = append(c.Projects, projectConfig{Main: mainFile})
+			fileGiven = true
 			continue
 		}
 
-		if lastProgramFile != "" && len(args) > i+1 {
-			programFiles[lastProgramFile] = append(programFiles[lastProgramFile], args[i:]...) // note that: the executable argument (1st arg) is set-ed by the exec.Command on `runProject`.
+		// it's a main package, its directory or its import path.
+		if !fileGiven && (isDirectory(a) || (last == -1 && !strings.HasPrefix(a, "-")) || isImportPath(a)) {
+			pkg := a
+			if isDirectory(a) {
+				pkg, _ = filepath.Abs(a)
+			}
+
+			c.Projects = append(c.Projects, projectConfig{Main: pkg})
 			continue
 		}
+
+		if last == -1 {
+			return nil, usageError(fmt.Sprintf("unexpected argument %q, expected a *.go file or a package", a))
+		}
+
+		// note that: the executable argument (1st arg) is set-ed by the exec.Command on `runProject`.
+		c.Projects[last].Args = append(c.Projects[last].Args, args[i:]...)
+		break
 	}
 
-	// no program files given
-	if len(programFiles) == 0 {
-		errorf("please provide a *.go file.\n")
-		help(-1)
-		return
+	return c, nil
+}
+
+func runCommand(args []string) error {
+	c, err := parseRun("run", args)
+	if err != nil {
+		return err
 	}
 
-	// check if given program files exist
-	for programFile := range programFiles {
-		// the argument is not the first  given is *.go but doesn't exists on user's disk
-		if p, _ := filepath.Abs(programFile); !fileExists(p) {
-			errorf("file " + p + " does not exists.\n")
-			help(-1)
-			return
+	// if "walk" then
+	//   use the stdlib's filepath.walk method instead of the operating system's signal.
+	//   It's only usage is when the user's IDE overrides the os' signals.
+	// otherwise
+	//   use the fsnotify's operating system's file system's signals.
+	fsWatcher, _ := rizla.WatcherFromFlag(c.Watcher, c.Interval)
+	rizla.OnReloadScripts = append(rizla.OnReloadScripts, c.OnReload...)
+	rizla.OnReloadedScripts = append(rizla.OnReloadedScripts, c.OnReloaded...)
+	if c.HookPolicy != "" {
+		rizla.DefaultHookPolicy, _ = rizla.ParseHookPolicy(c.HookPolicy)
+	}
+	if c.HookTimeout > 0 {
+		rizla.DefaultHookTimeout = c.HookTimeout
+	}
+
+	for _, pc := range c.Projects {
+		p, err := pc.project()
+		if err != nil {
+			return err
+		}
+		rizla.Add(p)
+	}
+
+	// stop the programs gracefully when rizla itself is interrupted or terminated.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	if err = rizla.RunContext(ctx, fsWatcher, nil, 0); err != nil && ctx.Err() == nil {
+		return err
+	}
+	return nil
+}
+
+func configPrintCommand(args []string) error {
+	c, err := parseRun("config print", args)
+	if err != nil {
+		return err
+	}
+
+	enc := toml.NewEncoder(os.Stdout)
+	enc.Indent = "" // like the rizla.toml of the README.
+	return enc.Encode(c.withDefaults())
+}
+
+const initTmpl = `# rizla configuration file, run "rizla" in this directory to use it.
+# All of the project's options can be overridden by the command line flags,
+# see "rizla help".
+
+# The file system's watcher, "signal" or "walk".
+watcher = "signal"
+# The polling interval of the "walk" watcher.
+# interval = "1.35s"
+# Commands to execute before each reload.
+# onreload = ["./on_reload.sh"]
+# Commands to execute after each reload, when the program has started or it's ready, see ready-url.
+# onreloaded = ["./warm_cache.sh"]
+# What happens when one of them fails: "abort" the reload, "continue" or "exit" rizla.
+# hook-policy = "abort"
+# hook-timeout = "1m"
+
+[[project]]
+# name = "my project"
+main = %q
+# args = ["-port", "8080"]
+# workdir = "."
+# roots = ["."]
+# disable-local-modules = false
+# watch-imports-only = false
+# delay = "1s"
+# allow-reload-after = "2s"
+# debounce = "100ms"
+# trigger-ops = ["create", "write", "remove", "rename"]
+# disable-content-hash = false
+# disable-runtime-dir = false
+# disable-program-rerun-output = false
+# include = ["*.go", "web/**/*.html"]
+# exclude = ["*_test.go", "tmp/"]
+# exclude-dirs = [".git", "node_modules", "vendor"]
+# disable-gitignore = false
+# stop-signal = "SIGTERM"
+# stop-timeout = "5s"
+# ports = [8080]
+# detect-ports = false
+# ports-timeout = "10s"
+# restart-on-crash = false
+# restart-backoff = "500ms"
+# max-restarts = 5
+# ready-url = "http://localhost:8080/health"
+# ready-status = 200
+# ready-addr = "localhost:8080"
+# ready-output = "listening on"
+# ready-timeout = "30s"
+# ready-interval = "250ms"
+# build-before-stop = false
+# build-tags = ["dev"]
+# ldflags = "-s -w"
+# gcflags = "all=-N -l"
+# race = false
+# build-args = ["-trimpath"]
+# build-env = ["CGO_ENABLED=0"]
+# output = "./bin/app"
+# build-command = "make build"
+# build-dir = "."
+
+# Commands which run in order before each build, a failed one stops the reload.
+# [[project.stage]]
+# name = "generate"
+# command = "go generate ./..."
+# dir = "."
+# triggers = ["*.go", "*.templ"] # run only when a matching file changes, defaults to every reload
+# timeout = "5m"
+
+# Commands which run in order before each reload, after the onreload ones.
+# The RIZLA_PROJECT, RIZLA_PROJECT_DIR, RIZLA_CHANGED_FILE and RIZLA_CHANGED_FILES environment variables are set.
+# [[project.onreload-hook]]
+# command = "./notify.sh 'reloading the api'"
+# policy = "continue"
+# timeout = "10s"
+
+# Commands which run in order after each reload, when the program is ready, after the onreloaded ones.
+# [[project.onreloaded-hook]]
+# command = "curl -fsS http://localhost:8080/health"
+# policy = "continue"
+`
+
+func initCommand(args []string) error {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	mainFile := fs.String("main", "main.go", "the project's main file")
+	force := fs.Bool("force", false, "overwrite the existing configuration file")
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return err
 		}
+		return usageError(err.Error())
+	}
+
+	if fs.NArg() > 0 {
+		return usageError(fmt.Sprintf("unexpected argument %q", fs.Arg(0)))
+	}
+
+	if fileExists(ConfigFilename) && !*force {
+		return errors.New(ConfigFilename + " already exists, use -force to overwrite it")
+	}
+
+	if err := os.WriteFile(ConfigFilename, []byte(fmt.Sprintf(initTmpl, *mainFile)), os.FileMode(0644)); err != nil {
+		return err
 	}
 
-	rizla.RunWith(fsWatcher, programFiles, delayOnDetect)
+	fmt.Printf("%s created.\n", ConfigFilename)
+	return nil
 }
 
-func help(code int) {
-	os.Stdout.WriteString(helpTmpl)
-	os.Exit(code)
+// isImportPath reports whether the "s" looks like a remote import path, i.e "example.com/svc/cmd/worker".
+func isImportPath(s string) bool {
+	slashIdx := strings.IndexByte(s, '/')
+	return slashIdx > 0 && strings.Contains(s[:slashIdx], ".") && !strings.HasPrefix(s, ".")
 }
 
-func isArgHelp(s string) bool {
-	return s == "help" || s == "-h" || s == "-help"
+func isDirectory(f string) bool {
+	info, err := os.Stat(f)
+	return err == nil && info.IsDir()
 }
 
 func fileExists(f string) bool {
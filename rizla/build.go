@@ -0,0 +1,127 @@
+package rizla
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// executableName returns the name of the program's executable file.
+func executableName(p *Project) string {
+	name := filepath.Base(p.dir)
+	if isWindows {
+		name += ".exe"
+	}
+	return name
+}
+
+// buildOutput returns the path of the program's executable file,
+// the project's Output or a file inside a temporary directory.
+func buildOutput(p *Project) (string, error) {
+	if p.Output != "" {
+		return filepath.Abs(p.Output)
+	}
+
+	if p.buildDir == "" {
+		dir, err := os.MkdirTemp("", "rizla-")
+		if err != nil {
+			return "", err
+		}
+		p.buildDir = dir
+	}
+
+	return filepath.Join(p.buildDir, executableName(p)), nil
+}
+
+// nextOutput returns the path which the build writes the executable file to
+// when the running program should keep running until the build succeeds,
+// it's the "output" with a ".next" suffix before its extension.
+func nextOutput(output string) string {
+	ext := filepath.Ext(output)
+	return strings.TrimSuffix(output, ext) + ".next" + ext
+}
+
+// removeBuildDir removes the temporary directory of the project's builds, if any.
+func removeBuildDir(p *Project) {
+	if p.buildDir != "" {
+		os.RemoveAll(p.buildDir)
+		p.buildDir = ""
+	}
+}
+
+// goBuildArgs returns the arguments of the go build command which writes the executable file to the "output".
+func goBuildArgs(p *Project, output string) []string {
+	args := []string{"build", "-o", output}
+
+	if len(p.BuildTags) > 0 {
+		args = append(args, "-tags", strings.Join(p.BuildTags, ","))
+	}
+
+	if p.LDFlags != "" {
+		args = append(args, "-ldflags", p.LDFlags)
+	}
+
+	if p.GCFlags != "" {
+		args = append(args, "-gcflags", p.GCFlags)
+	}
+
+	if p.Race {
+		args = append(args, "-race")
+	}
+
+	args = append(args, p.BuildArgs...)
+	// relative := p.MainFile[len(p.dir)+1:len(p.MainFile)-3] + goExt
+	return append(args, ".")
+}
+
+// buildProject builds the project and, on success,
+// sets the executable file which the next `runProject` runs.
+//
+// When the project's BuildBeforeStop is true the executable is written next to the running one,
+// which is replaced by the `runProject`, after the running program is stopped.
+func buildProject(ctx context.Context, p *Project) error {
+	output, err := buildOutput(p)
+	if err != nil {
+		return err
+	}
+
+	target := output
+	if p.BuildBeforeStop {
+		target = nextOutput(output)
+	}
+
+	var build *exec.Cmd
+	if len(p.BuildCommand) > 0 {
+		build = newGroupCommand(ctx, p.BuildCommand[0], p.BuildCommand[1:]...)
+		build.Dir = p.BuildDir
+	} else {
+		build = newGroupCommand(ctx, "go", goBuildArgs(p, target)...)
+	}
+
+	if build.Dir == "" {
+		build.Dir = p.dir
+	}
+	build.Env = append(append(os.Environ(), p.BuildEnv...), "RIZLA_OUTPUT="+target)
+	build.Stdout = p.Out.Printer.Output
+	build.Stderr = p.Err.Printer.Output
+
+	if err = build.Run(); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		return err
+	}
+
+	p.executable = output
+	p.nextExecutable = ""
+	if target != output {
+		if _, err = os.Stat(target); err == nil {
+			p.nextExecutable = target
+		}
+		// else a custom build command wrote the output directly.
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package rizla
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGoBuildArgs(t *testing.T) {
+	p := NewProject("build_test.go")
+	if expected, got := []string{"build", "-o", "app", "."}, goBuildArgs(p, "app"); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+
+	p.BuildTags = []string{"dev", "sqlite"}
+	p.LDFlags = "-s -w"
+	p.GCFlags = "all=-N -l"
+	p.Race = true
+	p.BuildArgs = []string{"-trimpath"}
+
+	expected := []string{"build", "-o", "app", "-tags", "dev,sqlite", "-ldflags", "-s -w", "-gcflags", "all=-N -l", "-race", "-trimpath", "."}
+	if got := goBuildArgs(p, "app"); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected %v but got %v", expected, got)
+	}
+}
+
+func TestNextOutput(t *testing.T) {
+	tests := map[string]string{
+		"/tmp/rizla-1/app":     "/tmp/rizla-1/app.next",
+		"/tmp/rizla-1/app.exe": "/tmp/rizla-1/app.next.exe",
+	}
+
+	for output, expected := range tests {
+		if got := nextOutput(output); got != expected {
+			t.Fatalf("expected %s but got %s", expected, got)
+		}
+	}
+}
+
+func TestBuildCommand(t *testing.T) {
+	p := newTestProgram(t, sleeperProgram)
+	p.Output = filepath.Join(p.dir, "bin", executableName(p))
+	p.BuildCommand = []string{"go", "build", "-o", p.Output, "."}
+	p.BuildEnv = []string{"CGO_ENABLED=0"}
+
+	if err := buildProject(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.executable != p.Output {
+		t.Fatalf("expected the executable to be %s but got %s", p.Output, p.executable)
+	}
+
+	if _, err := os.Stat(p.Output); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.buildDir != "" {
+		t.Fatal("expected no temporary build directory when the output is set")
+	}
+
+	// the main package inside a sub directory of the module, the build command runs in the module's root.
+	root := p.dir
+	p.dir = filepath.Join(root, "app")
+	p.MainFile = filepath.Join(p.dir, "main.go")
+	if err := os.MkdirAll(p.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(filepath.Join(root, "main.go"), p.MainFile); err != nil {
+		t.Fatal(err)
+	}
+	os.Remove(p.Output)
+
+	p.BuildCommand = []string{"go", "build", "-o", p.Output, "./app"}
+	p.BuildDir = root
+	if err := buildProject(context.Background(), p); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(p.Output); err != nil {
+		t.Fatal(err)
+	}
+}
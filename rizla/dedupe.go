@@ -0,0 +1,52 @@
+package rizla
+
+import "time"
+
+// DefaultDuplicateWindow is the window of the duplicate changes,
+// a change of the same path and operation within that window after the first one is dropped,
+// i.e the two writes that some systems, like windows, report on a single save.
+var DefaultDuplicateWindow = 100 * time.Millisecond
+
+// dedupeFilter drops the duplicate changes, see `DefaultDuplicateWindow`.
+type dedupeFilter struct {
+	window time.Duration
+	// now returns the current time, it's replaced by tests.
+	now  func() time.Time
+	last map[string]dedupeEntry
+}
+
+type dedupeEntry struct {
+	op   Op
+	time time.Time
+}
+
+// maxDedupeEntries is the number of the remembered changes which triggers a cleanup of the stale ones.
+const maxDedupeEntries = 512
+
+func newDedupeFilter(window time.Duration) *dedupeFilter {
+	return &dedupeFilter{
+		window: window,
+		now:    time.Now,
+		last:   make(map[string]dedupeEntry),
+	}
+}
+
+// duplicate reports whether a change of the "path" with the same "op"
+// was seen within the window, the window starts on the first change.
+func (f *dedupeFilter) duplicate(path string, op Op) bool {
+	now := f.now()
+	if e, ok := f.last[path]; ok && e.op == op && now.Sub(e.time) < f.window {
+		return true
+	}
+
+	if len(f.last) >= maxDedupeEntries {
+		for p, e := range f.last {
+			if now.Sub(e.time) >= f.window {
+				delete(f.last, p)
+			}
+		}
+	}
+
+	f.last[path] = dedupeEntry{op: op, time: now}
+	return false
+}
@@ -0,0 +1,86 @@
+package rizla
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+func TestDedupeFilter(t *testing.T) {
+	now := time.Now()
+	f := newDedupeFilter(100 * time.Millisecond)
+	f.now = func() time.Time { return now }
+
+	tests := []struct {
+		after     time.Duration
+		path      string
+		op        Op
+		duplicate bool
+	}{
+		{0, "main.go", Write, false},
+		{10 * time.Millisecond, "main.go", Write, true},
+		{0, "main.go", Create, false}, // another operation.
+		{0, "other.go", Write, false}, // another path.
+		{50 * time.Millisecond, "main.go", Create, true},
+		{100 * time.Millisecond, "main.go", Create, false}, // out of the window.
+	}
+
+	for i, tt := range tests {
+		now = now.Add(tt.after)
+		if got := f.duplicate(tt.path, tt.op); got != tt.duplicate {
+			t.Fatalf("[%d] %s %s: expected duplicate: %v but got %v", i, tt.op, tt.path, tt.duplicate, got)
+		}
+	}
+}
+
+// fakeSource is an eventSource which its events are sent by the tests.
+type fakeSource struct {
+	events chan fsnotify.Event
+	errors chan error
+}
+
+func (s *fakeSource) Add(string) error              { return nil }
+func (s *fakeSource) Events() <-chan fsnotify.Event { return s.events }
+func (s *fakeSource) Errors() <-chan error          { return s.errors }
+func (s *fakeSource) Close() error                  { return nil }
+
+func TestSignalWatcherDuplicates(t *testing.T) {
+	p := newRouterTestProject(t, t.TempDir())
+
+	source := &fakeSource{events: make(chan fsnotify.Event), errors: make(chan error)}
+	w := &signalWatcher{newSource: func() (eventSource, error) { return source, nil }}
+
+	var got []ChangeEvent
+	w.OnChange(func(evt ChangeEvent) {
+		got = append(got, evt)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- w.Loop(ctx, []*Project{p})
+	}()
+
+	filename := filepath.Join(p.dir, "main.go")
+	for _, op := range []fsnotify.Op{fsnotify.Write, fsnotify.Write, fsnotify.Chmod, fsnotify.Write} {
+		source.events <- fsnotify.Event{Name: filename, Op: op}
+	}
+	// the unbuffered channel makes sure that the previous event was handled.
+	source.events <- fsnotify.Event{Name: filepath.Join(p.dir, "other.go"), Op: fsnotify.Create}
+
+	cancel()
+	<-errCh
+
+	expected := []Op{Write, Chmod, Write}
+	if len(got) < len(expected) {
+		t.Fatalf("expected %d changes but got %d: %v", len(expected), len(got), got)
+	}
+	for i, op := range expected {
+		if got[i].Op != op || got[i].Path != filename {
+			t.Fatalf("[%d] expected %s %s but got %s", i, op, filename, got[i])
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package rizla
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// importGraph contains the packages which a main package imports,
+// including itself, and the files which they embed.
+type importGraph struct {
+	dirs map[string]bool
+	// files the go files of the packages' builds.
+	files map[string]bool
+	// ignored the go files of the packages' directories which are not part of their builds,
+	// i.e the tests and the ones which their build constraints exclude.
+	ignored map[string]bool
+	// embeds the files which the packages embed.
+	embeds map[string]bool
+}
+
+// goListPackage is the part of the "go list -json" output that the importGraph needs.
+type goListPackage struct {
+	Dir            string
+	Standard       bool
+	GoFiles        []string
+	CgoFiles       []string
+	IgnoredGoFiles []string
+	TestGoFiles    []string
+	XTestGoFiles   []string
+	EmbedFiles     []string
+}
+
+// loadImportGraph returns the import graph of the project's main package,
+// by the "go list -deps -json" command.
+func loadImportGraph(p *Project) (*importGraph, error) {
+	args := []string{"list", "-deps", "-json"}
+	if len(p.BuildTags) > 0 {
+		args = append(args, "-tags", strings.Join(p.BuildTags, ","))
+	}
+	args = append(args, ".")
+
+	var stderr bytes.Buffer
+	goList := exec.Command("go", args...)
+	goList.Dir = p.dir
+	goList.Env = append(os.Environ(), p.BuildEnv...)
+	goList.Stderr = &stderr
+	out, err := goList.Output()
+	if err != nil {
+		if stderr.Len() > 0 {
+			return nil, &buildError{err: err, output: strings.TrimSpace(stderr.String())}
+		}
+		return nil, err
+	}
+
+	g := &importGraph{
+		dirs:    make(map[string]bool),
+		files:   make(map[string]bool),
+		ignored: make(map[string]bool),
+		embeds:  make(map[string]bool),
+	}
+
+	add := func(set map[string]bool, dir string, files ...[]string) {
+		for _, names := range files {
+			for _, name := range names {
+				set[filepath.Join(dir, name)] = true
+			}
+		}
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(out))
+	for {
+		var pkg goListPackage
+		if err = dec.Decode(&pkg); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+
+		if pkg.Standard || pkg.Dir == "" {
+			continue
+		}
+
+		g.dirs[pkg.Dir] = true
+		add(g.files, pkg.Dir, pkg.GoFiles, pkg.CgoFiles)
+		add(g.ignored, pkg.Dir, pkg.IgnoredGoFiles, pkg.TestGoFiles, pkg.XTestGoFiles)
+		add(g.embeds, pkg.Dir, pkg.EmbedFiles)
+	}
+
+	return g, nil
+}
+
+// contains reports whether the "filename" belongs to one of the imported packages or it's embedded by them.
+// A file which is not known yet, i.e a new one, belongs to the package of its directory.
+func (g *importGraph) contains(filename string) bool {
+	if g.files[filename] || g.embeds[filename] {
+		return true
+	}
+	return !g.ignored[filename] && g.dirs[filepath.Dir(filename)]
+}
+
+// buildError is an error of a go command along with its output.
+type buildError struct {
+	err    error
+	output string
+}
+
+func (e *buildError) Error() string {
+	return e.err.Error() + ": " + e.output
+}
+
+// isModFile reports whether the "filename" is a go.mod, go.sum or go.work file,
+// their changes may change the import graph.
+func isModFile(filename string) bool {
+	switch filepath.Base(filename) {
+	case "go.mod", "go.sum", "go.work", "go.work.sum":
+		return true
+	}
+	return false
+}
+
+// loadImports loads the import graph of the project,
+// on failure every change is considered as an imported one, until the next successful load.
+func (p *Project) loadImports() {
+	g, err := loadImportGraph(p)
+	if err != nil {
+		p.Err.Warnf("%scould not load the imports, reloading on every change: %v", p.logPrefix(), err)
+	}
+	p.imports.Store(g)
+}
+
+// imported reports whether the "filename" belongs to the project's import graph,
+// it's always true if WatchImportsOnly is false.
+func (p *Project) imported(filename string) bool {
+	if !p.WatchImportsOnly {
+		return true
+	}
+
+	g := p.imports.Load()
+	return g == nil || g.contains(filename)
+}
+
+// embedded reports whether the "filename" is embedded by the packages of the project's import graph.
+func (p *Project) embedded(filename string) bool {
+	g := p.imports.Load()
+	return g != nil && g.embeds[filename]
+}
+
+// watches reports whether a change of the "filename" should fire a reload,
+// the files which are accepted by the project's Matcher and, when WatchImportsOnly is true,
+// the go.mod and go.work files, because they may change the imports, and the embedded files.
+func (p *Project) watches(filename string) bool {
+	return p.Matcher(filename) || p.WatchImportsOnly && (isModFile(filename) || p.embedded(filename))
+}
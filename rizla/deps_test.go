@@ -0,0 +1,111 @@
+package rizla
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newImportsTestProgram returns a program which imports its lib package,
+// the lib package embeds the "embedFile" and the "otherFile" is not imported.
+func newImportsTestProgram(t *testing.T) (p *Project, libFile, embedFile, otherFile string) {
+	p = newTestProgram(t, `package main
+
+import (
+	"time"
+
+	"program/lib"
+)
+
+func main() {
+	lib.Hello()
+	time.Sleep(time.Hour)
+}
+`)
+	// the imports of the test program are resolved by its go.mod.
+	t.Setenv("GO111MODULE", "on")
+
+	write := func(name, contents string) string {
+		filename := filepath.Join(p.dir, name)
+		if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	libFile = write("lib/lib.go", "package lib\n\nimport _ \"embed\"\n\n//go:embed hello.txt\nvar hello string\n\nfunc Hello() { println(hello) }\n")
+	embedFile = write("lib/hello.txt", "hello")
+	otherFile = write("other/other.go", "package other\n")
+	write("go.mod", "module program\n\ngo 1.21\n")
+
+	p.WatchImportsOnly = true
+	return
+}
+
+func TestImportGraph(t *testing.T) {
+	p, libFile, embedFile, otherFile := newImportsTestProgram(t)
+	// the files of the lib's directory which are not part of its build.
+	testFile := filepath.Join(p.dir, "lib", "lib_test.go")
+	ignoredFile := filepath.Join(p.dir, "lib", "ignored.go")
+	if err := os.WriteFile(testFile, []byte("package lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(ignoredFile, []byte("//go:build ignore\n\npackage lib\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p.loadImports()
+	if p.imports.Load() == nil {
+		t.Fatal("expected the import graph to be loaded")
+	}
+
+	tests := []struct {
+		filename string
+		imported bool
+	}{
+		{p.MainFile, true},
+		{libFile, true},
+		{embedFile, true},
+		{otherFile, false},
+		{testFile, false},
+		{ignoredFile, false},
+		{filepath.Join(p.dir, "lib", "new.go"), true},
+	}
+
+	for i, tt := range tests {
+		if got := p.imported(tt.filename); got != tt.imported {
+			t.Fatalf("[%d] expected %s to be imported: %v but got %v", i, tt.filename, tt.imported, got)
+		}
+	}
+
+	p.WatchImportsOnly = false
+	if !p.imported(otherFile) {
+		t.Fatalf("expected every file to be accepted when WatchImportsOnly is false")
+	}
+}
+
+func TestReloadEmbeddedFile(t *testing.T) {
+	p, _, embedFile, _ := newImportsTestProgram(t)
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+	}()
+
+	if err := os.WriteFile(embedFile, []byte("hello again"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// the default Include doesn't match the embedded file.
+	w.change(p, embedFile)
+	if expected, got := 2, p.Runs(); expected != got {
+		t.Fatalf("expected the program to run %d times but it ran %d times", expected, got)
+	}
+}
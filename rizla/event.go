@@ -0,0 +1,101 @@
+package rizla
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Op describes the operations of a `ChangeEvent`, a set of bits.
+type Op uint32
+
+// The operations of a `ChangeEvent`.
+const (
+	// Create a file or a directory was created.
+	Create Op = 1 << iota
+	// Write a file was modified.
+	Write
+	// Remove a file or a directory was removed.
+	Remove
+	// Rename a file or a directory was renamed, the event's path is the old one.
+	Rename
+	// Chmod the attributes of a file or a directory were changed.
+	Chmod
+)
+
+// DefaultTriggerOps are the default `Project#TriggerOps`, every operation except Chmod.
+var DefaultTriggerOps = Create | Write | Remove | Rename
+
+var opNames = []struct {
+	op   Op
+	name string
+}{
+	{Create, "CREATE"},
+	{Write, "WRITE"},
+	{Remove, "REMOVE"},
+	{Rename, "RENAME"},
+	{Chmod, "CHMOD"},
+}
+
+// String returns the names of the operations separated by "|", i.e "CREATE|WRITE".
+func (op Op) String() string {
+	var names []string
+	for _, o := range opNames {
+		if op&o.op == o.op {
+			names = append(names, o.name)
+		}
+	}
+	return strings.Join(names, "|")
+}
+
+// ParseOp returns the operation of a name, case-insensitive, i.e "write" or "CREATE".
+func ParseOp(name string) (Op, error) {
+	for _, o := range opNames {
+		if strings.EqualFold(o.name, name) {
+			return o.op, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown operation %q, expected one of create, write, remove, rename or chmod", name)
+}
+
+// ChangeEvent is a change of a file or a directory of a project.
+type ChangeEvent struct {
+	// Project is the project which watches the changed file.
+	Project *Project
+	// Path is the path of the changed file or directory.
+	Path string
+	// RelPath is the Path relative to the project's watched root which contains it,
+	// or its base name if no root contains it.
+	RelPath string
+	// Op is the operation of the change.
+	Op Op
+	// Time is when the change was detected.
+	Time time.Time
+}
+
+// NewChangeEvent returns a change event of the "path", detected now.
+func NewChangeEvent(p *Project, path string, op Op) ChangeEvent {
+	_, rel := p.relPath(path)
+	return ChangeEvent{
+		Project: p,
+		Path:    path,
+		RelPath: filepath.FromSlash(rel),
+		Op:      op,
+		Time:    time.Now(),
+	}
+}
+
+// String returns the operation and the relative path of the change, i.e "WRITE main.go".
+func (evt ChangeEvent) String() string {
+	return evt.Op.String() + " " + evt.RelPath
+}
+
+// triggers reports whether the event's operation fires a reload, see `Project#TriggerOps`.
+func (p *Project) triggers(evt ChangeEvent) bool {
+	ops := p.TriggerOps
+	if ops == 0 {
+		ops = DefaultTriggerOps
+	}
+	return evt.Op&ops != 0
+}
@@ -0,0 +1,61 @@
+package rizla
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestOp(t *testing.T) {
+	if expected, got := "CREATE|WRITE", (Create | Write).String(); expected != got {
+		t.Fatalf("expected %s but got %s", expected, got)
+	}
+
+	for _, name := range []string{"create", "WRITE", "Remove", "rename", "chmod"} {
+		op, err := ParseOp(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.EqualFold(op.String(), name) {
+			t.Fatalf("expected %s but got %s", name, op)
+		}
+	}
+
+	if _, err := ParseOp("delete"); err == nil {
+		t.Fatal("expected an error for an unknown operation")
+	}
+}
+
+func TestChangeEvent(t *testing.T) {
+	p := newTestProject()
+	filename := filepath.Join(p.dir, "views", "index.html")
+
+	evt := NewChangeEvent(p, filename, Create)
+	if evt.Project != p || evt.Path != filename || evt.Op != Create || evt.Time.IsZero() {
+		t.Fatalf("unexpected event %#v", evt)
+	}
+
+	if expected := filepath.Join("views", "index.html"); evt.RelPath != expected {
+		t.Fatalf("expected the relative path %s but got %s", expected, evt.RelPath)
+	}
+
+	if !p.triggers(evt) || p.triggers(NewChangeEvent(p, filename, Chmod)) {
+		t.Fatal("expected the default operations, except chmod, to fire a reload")
+	}
+
+	p.TriggerOps = Write
+	if p.triggers(evt) {
+		t.Fatal("expected a create to not fire a reload when only the writes do")
+	}
+
+	// changes of the same file are merged.
+	rl := newReloader(p)
+	rl.add(evt)
+	rl.add(NewChangeEvent(p, filename, Write))
+	rl.add(NewChangeEvent(p, p.MainFile, Remove))
+
+	changes := rl.take(nil)
+	if len(changes) != 2 || changes[0].Op != Create|Write || changes[1].Op != Remove {
+		t.Fatalf("expected two changes, the merged one first, but got %v", changes)
+	}
+}
@@ -0,0 +1,79 @@
+package rizla
+
+import (
+	"crypto/sha256"
+	"io"
+	"os"
+)
+
+// contentHash is the hash of a file's content.
+type contentHash [sha256.Size]byte
+
+// fileHashes are the content hashes of the watched files at the last build, by their paths.
+type fileHashes map[string]contentHash
+
+// hashFile returns the content hash of the file.
+func hashFile(filename string) (h contentHash, err error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+
+	hasher := sha256.New()
+	if _, err = io.Copy(hasher, f); err != nil {
+		return
+	}
+
+	copy(h[:], hasher.Sum(nil))
+	return
+}
+
+// hashFiles returns the content hashes of the files which the project watches.
+func hashFiles(p *Project) fileHashes {
+	hashes := make(fileHashes)
+	for filename := range takeSnapshot(p, p.watchRoots()) {
+		if h, err := hashFile(filename); err == nil {
+			hashes[filename] = h
+		}
+	}
+	return hashes
+}
+
+// effective returns the changes which their content is not the same as the last build,
+// and the content hashes of them, if any.
+// The removed and the renamed files, and the files which can't be read, i.e directories, are always effective.
+func (hashes fileHashes) effective(changes []ChangeEvent) ([]ChangeEvent, fileHashes) {
+	var (
+		effective = changes[:0:0]
+		changed   = make(fileHashes)
+	)
+
+	for _, evt := range changes {
+		if evt.Op&(Remove|Rename) == 0 {
+			if h, err := hashFile(evt.Path); err == nil {
+				if last, ok := hashes[evt.Path]; ok && last == h {
+					continue
+				}
+				changed[evt.Path] = h
+			}
+		}
+
+		effective = append(effective, evt)
+	}
+
+	return effective, changed
+}
+
+// update stores the content hashes of the built "changes".
+func (hashes fileHashes) update(changes []ChangeEvent, changed fileHashes) {
+	for _, evt := range changes {
+		if h, ok := changed[evt.Path]; ok {
+			hashes[evt.Path] = h
+			continue
+		}
+
+		// removed, renamed or no longer readable.
+		delete(hashes, evt.Path)
+	}
+}
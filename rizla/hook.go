@@ -0,0 +1,267 @@
+package rizla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// HookPolicy describes what happens when a `Hook` fails.
+type HookPolicy uint8
+
+// The failure policies of a `Hook`.
+const (
+	// HookAbort stops the reload, the running program keeps running.
+	HookAbort HookPolicy = iota + 1
+	// HookContinue reports the failure and the reload continues.
+	HookContinue
+	// HookExit stops rizla, the `Runner#RunContext` returns the hook's error.
+	HookExit
+)
+
+var hookPolicyNames = map[HookPolicy]string{
+	HookAbort:    "abort",
+	HookContinue: "continue",
+	HookExit:     "exit",
+}
+
+// String returns the name of the policy, i.e "abort".
+func (policy HookPolicy) String() string {
+	if name, ok := hookPolicyNames[policy]; ok {
+		return name
+	}
+	return fmt.Sprintf("HookPolicy(%d)", uint8(policy))
+}
+
+// ParseHookPolicy returns the policy of a name, case-insensitive, i.e "abort", "continue" or "exit".
+func ParseHookPolicy(name string) (HookPolicy, error) {
+	for policy, policyName := range hookPolicyNames {
+		if strings.EqualFold(policyName, name) {
+			return policy, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown hook policy %q, expected abort, continue or exit", name)
+}
+
+// DefaultHookPolicy is the default `Hook#Policy`.
+var DefaultHookPolicy = HookAbort
+
+// DefaultHookTimeout is the default `Hook#Timeout`.
+var DefaultHookTimeout = time.Minute
+
+// Hook is a command which runs on the reload of a project, see `Project#OnReloadHooks` and `Project#OnReloadedHooks`.
+//
+// The command runs inside the project's directory and its environment has the variables:
+//   - RIZLA_PROJECT the name of the project or, if it's empty, the base name of its directory
+//   - RIZLA_PROJECT_DIR the directory of the project
+//   - RIZLA_CHANGED_FILE the first changed file
+//   - RIZLA_CHANGED_FILES the changed files, separated by the `os.PathListSeparator`.
+type Hook struct {
+	// Command is the command line, its arguments are split by the rules of `SplitCommand`,
+	// i.e `./notify.sh "the api" --verbose`.
+	Command string
+	// Policy is what happens when the command fails or it times out.
+	// Defaults to `DefaultHookPolicy`.
+	Policy HookPolicy
+	// Timeout is the maximum duration of the command, it's killed after that.
+	// Defaults to `DefaultHookTimeout`.
+	Timeout time.Duration
+}
+
+func (h Hook) policy() HookPolicy {
+	if h.Policy == 0 {
+		return DefaultHookPolicy
+	}
+	return h.Policy
+}
+
+func (h Hook) timeout() time.Duration {
+	if h.Timeout <= 0 {
+		return DefaultHookTimeout
+	}
+	return h.Timeout
+}
+
+// hookError is the error of a failed hook which its policy is not the HookContinue.
+type hookError struct {
+	hook Hook
+	err  error
+}
+
+func (e *hookError) Error() string {
+	return fmt.Sprintf("hook %s: %v", e.hook.Command, e.err)
+}
+
+func (e *hookError) Unwrap() error {
+	return e.err
+}
+
+// SplitCommand splits a command line to its arguments with the quoting rules of a shell:
+// arguments are separated by whitespaces, the text inside single quotes is literal
+// and the text inside double quotes is literal except the backslash escapes of the `"` and `\`.
+// Outside of quotes a backslash escapes a whitespace, a quote or a backslash,
+// otherwise it's literal, so windows paths, like C:\scripts\notify.bat, are kept as they are.
+func SplitCommand(s string) ([]string, error) {
+	var (
+		args  []string
+		arg   strings.Builder
+		inArg bool
+		quote rune
+	)
+
+	runes := []rune(s)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case quote == '\'':
+			if r == '\'' {
+				quote = 0
+				continue
+			}
+		case quote == '"':
+			if r == '"' {
+				quote = 0
+				continue
+			}
+			if r == '\\' && i+1 < len(runes) && (runes[i+1] == '"' || runes[i+1] == '\\') {
+				i++
+				r = runes[i]
+			}
+		case r == '\'' || r == '"':
+			quote = r
+			inArg = true
+			continue
+		case r == ' ' || r == '\t' || r == '\n' || r == '\r':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+			continue
+		case r == '\\' && i+1 < len(runes) && strings.ContainsRune(" \t'\"\\", runes[i+1]):
+			i++
+			r = runes[i]
+		}
+
+		arg.WriteRune(r)
+		inArg = true
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in %s", quote, s)
+	}
+
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args, nil
+}
+
+// onReloadHooks returns the hooks which run before the reload of the project,
+// the ones of the `OnReloadScripts` first.
+func (p *Project) onReloadHooks() []Hook {
+	hooks := make([]Hook, 0, len(OnReloadScripts)+len(p.OnReloadHooks))
+	for _, s := range OnReloadScripts {
+		hooks = append(hooks, Hook{Command: s})
+	}
+	return append(hooks, p.OnReloadHooks...)
+}
+
+// onReloadedHooks returns the hooks which run after the reload of the project,
+// the ones of the `OnReloadedScripts` first.
+// The reload is already done, so the hooks with the HookAbort policy continue on failure.
+func (p *Project) onReloadedHooks() []Hook {
+	hooks := make([]Hook, 0, len(OnReloadedScripts)+len(p.OnReloadedHooks))
+	for _, s := range OnReloadedScripts {
+		hooks = append(hooks, Hook{Command: s})
+	}
+	hooks = append(hooks, p.OnReloadedHooks...)
+
+	for i := range hooks {
+		if hooks[i].policy() == HookAbort {
+			hooks[i].Policy = HookContinue
+		}
+	}
+	return hooks
+}
+
+// runHooks runs the "hooks" in order, the failures of the hooks with the HookContinue policy are reported,
+// the rest of them stop the run and their error is returned.
+func runHooks(ctx context.Context, p *Project, hooks []Hook, changes []ChangeEvent) error {
+	for _, h := range hooks {
+		err := runHook(ctx, p, h, changes)
+		if err == nil {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		if h.policy() == HookContinue {
+			p.Err.Errorf("%shook %s: %v", p.logPrefix(), h.Command, err)
+			continue
+		}
+
+		return &hookError{hook: h, err: err}
+	}
+
+	return nil
+}
+
+func runHook(ctx context.Context, p *Project, h Hook, changes []ChangeEvent) error {
+	args, err := SplitCommand(h.Command)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		return errors.New("empty command")
+	}
+
+	timeout := h.timeout()
+	hookCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	p.Out.Infof("%sExecuting %s...", p.logPrefix(), h.Command)
+
+	cmd := newGroupCommand(hookCtx, args[0], args[1:]...)
+	cmd.Dir = p.dir
+	cmd.Env = append(os.Environ(), hookEnv(p, changes)...)
+	cmd.Stdout = p.Out.Printer.Output
+	cmd.Stderr = p.Err.Printer.Output
+
+	if err = cmd.Run(); err != nil && hookCtx.Err() == context.DeadlineExceeded && ctx.Err() == nil {
+		err = fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
+
+// hookEnv returns the environment variables of the hooks, see `Hook`.
+func hookEnv(p *Project, changes []ChangeEvent) []string {
+	name := p.Name
+	if name == "" {
+		name = filepath.Base(p.dir)
+	}
+
+	files := make([]string, len(changes))
+	for i, evt := range changes {
+		files[i] = evt.Path
+	}
+
+	first := ""
+	if len(files) > 0 {
+		first = files[0]
+	}
+
+	return []string{
+		"RIZLA_PROJECT=" + name,
+		"RIZLA_PROJECT_DIR=" + p.dir,
+		"RIZLA_CHANGED_FILE=" + first,
+		"RIZLA_CHANGED_FILES=" + strings.Join(files, string(os.PathListSeparator)),
+	}
+}
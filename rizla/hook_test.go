@@ -0,0 +1,84 @@
+package rizla
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	tests := []struct {
+		command string
+		args    []string
+	}{
+		{"service supervisor restart", []string{"service", "supervisor", "restart"}},
+		{"  go   vet\t./... ", []string{"go", "vet", "./..."}},
+		{`./notify.sh "the api" --verbose`, []string{"./notify.sh", "the api", "--verbose"}},
+		{`echo 'a "quoted" word' "it's" ""`, []string{"echo", `a "quoted" word`, "it's", ""}},
+		{`echo "a \"b\" \\ \c"`, []string{"echo", `a "b" \ \c`}},
+		{`echo a\ b`, []string{"echo", "a b"}},
+		{`C:\scripts\notify.bat --name=x"y z"`, []string{`C:\scripts\notify.bat`, "--name=xy z"}},
+		{"", nil},
+	}
+
+	for _, tt := range tests {
+		args, err := SplitCommand(tt.command)
+		if err != nil {
+			t.Fatalf("%s: %v", tt.command, err)
+		}
+
+		if !reflect.DeepEqual(tt.args, args) {
+			t.Fatalf("%s: expected the arguments %q but got %q", tt.command, tt.args, args)
+		}
+	}
+
+	for _, command := range []string{`echo "unterminated`, `echo 'unterminated`} {
+		if _, err := SplitCommand(command); err == nil {
+			t.Fatalf("%s: expected an error", command)
+		}
+	}
+}
+
+func TestRunHooks(t *testing.T) {
+	p := newTestProject()
+	p.dir = t.TempDir()
+
+	hooks := []Hook{
+		{Command: "go no-such-command", Policy: HookContinue},
+		{Command: "go env GOROOT"},
+		{Command: "go no-such-command"},
+		{Command: "go mod init never"},
+	}
+
+	err := runHooks(context.Background(), p, hooks, nil)
+	var herr *hookError
+	if !errors.As(err, &herr) || herr.hook != hooks[2] || herr.hook.policy() != HookAbort {
+		t.Fatalf("expected the third hook to stop the run with the default policy but got %v", err)
+	}
+
+	if fileExists(filepath.Join(p.dir, "go.mod")) {
+		t.Fatal("expected the hooks after the failed one to not run")
+	}
+}
+
+func TestHookEnv(t *testing.T) {
+	if isWindows {
+		t.Skip("the hook command needs a shell")
+	}
+
+	p := newTestProject()
+	p.Name = "api"
+	p.dir = t.TempDir()
+	changes := []ChangeEvent{
+		NewChangeEvent(p, filepath.Join(p.dir, "main.go"), Write),
+		NewChangeEvent(p, filepath.Join(p.dir, "other.go"), Create),
+	}
+
+	check := `test "$RIZLA_PROJECT" = api && test "$RIZLA_PROJECT_DIR" = "$0" && test "$RIZLA_CHANGED_FILE" = "$0/main.go" && test "$RIZLA_CHANGED_FILES" = "$0/main.go:$0/other.go"`
+	h := Hook{Command: "sh -c '" + check + "' " + p.dir}
+	if err := runHooks(context.Background(), p, []Hook{h}, changes); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,166 @@
+package rizla
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// findUp returns the path of the "name" file inside the "dir" or inside its nearest parent directory,
+// or an empty string if not found.
+func findUp(dir, name string) string {
+	for {
+		if filename := filepath.Join(dir, name); fileExists(filename) {
+			return filename
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+// modDirectives returns the arguments of each "directive" of a go.mod or go.work file's contents,
+// both the single line form, i.e `use ./shared`, and the block form, i.e `use ( ./a ./b )`.
+func modDirectives(data []byte, directive string) []string {
+	var (
+		values  []string
+		inBlock bool
+	)
+
+	for _, line := range strings.Split(string(data), "\n") {
+		if commentIdx := strings.Index(line, "//"); commentIdx >= 0 {
+			line = line[:commentIdx]
+		}
+		line = strings.TrimSpace(line)
+
+		if inBlock {
+			if line == ")" {
+				inBlock = false
+			} else if line != "" {
+				values = append(values, line)
+			}
+			continue
+		}
+
+		if !strings.HasPrefix(line, directive) {
+			continue
+		}
+
+		rest := strings.TrimSpace(line[len(directive):])
+		if rest == line[len(directive):] && rest != "" && rest[0] != '(' {
+			// not followed by a space, i.e "user" instead of "use".
+			continue
+		}
+
+		if rest == "(" {
+			inBlock = true
+		} else if rest != "" {
+			values = append(values, rest)
+		}
+	}
+
+	return values
+}
+
+// isLocalPath reports whether a go.mod's path is a file system's path and not a module path.
+func isLocalPath(path string) bool {
+	return strings.HasPrefix(path, "./") || strings.HasPrefix(path, "../") ||
+		strings.HasPrefix(path, ".\\") || strings.HasPrefix(path, "..\\") ||
+		path == "." || path == ".." || filepath.IsAbs(path)
+}
+
+// localReplaces returns the directories of the replace directives,
+// of a go.mod or a go.work file's contents, which point to a local directory.
+func localReplaces(data []byte) []string {
+	var dirs []string
+	for _, replace := range modDirectives(data, "replace") {
+		// old [version] => new [version]
+		arrowIdx := strings.Index(replace, "=>")
+		if arrowIdx == -1 {
+			continue
+		}
+
+		if fields := strings.Fields(replace[arrowIdx+2:]); len(fields) == 1 && isLocalPath(fields[0]) {
+			dirs = append(dirs, unquoteModPath(fields[0]))
+		}
+	}
+	return dirs
+}
+
+// workspaceUses returns the directories of the use directives of a go.work file's contents.
+func workspaceUses(data []byte) []string {
+	var dirs []string
+	for _, use := range modDirectives(data, "use") {
+		dirs = append(dirs, unquoteModPath(use))
+	}
+	return dirs
+}
+
+func unquoteModPath(path string) string {
+	return strings.Trim(path, "\"`")
+}
+
+// localModuleDirs returns the directories of the local modules which the module of the "dir" depends on,
+// the local replacements of its go.mod and the modules of its go.work workspace, if any.
+// The module of the "dir" itself is not included.
+func localModuleDirs(dir string) []string {
+	var (
+		dirs    []string
+		modDir  string
+		collect = func(filename string, parse func([]byte) []string) {
+			data, err := os.ReadFile(filename)
+			if err != nil {
+				return
+			}
+
+			for _, d := range parse(data) {
+				if !filepath.IsAbs(d) {
+					d = filepath.Join(filepath.Dir(filename), d)
+				}
+				dirs = append(dirs, filepath.Clean(d))
+			}
+		}
+	)
+
+	if goMod := findUp(dir, "go.mod"); goMod != "" {
+		modDir = filepath.Dir(goMod)
+		collect(goMod, localReplaces)
+	}
+
+	goWork := os.Getenv("GOWORK")
+	if goWork == "" {
+		goWork = findUp(dir, "go.work")
+	} else if goWork == "off" {
+		goWork = ""
+	}
+
+	if goWork != "" {
+		collect(goWork, workspaceUses)
+		collect(goWork, localReplaces)
+	}
+
+	var result []string
+	seen := map[string]bool{modDir: true}
+	for _, d := range dirs {
+		if !seen[d] && isDirectory(d) {
+			seen[d] = true
+			result = append(result, d)
+		}
+	}
+
+	return result
+}
+
+// isInside reports whether the "path" is the "dir" or it's inside the "dir".
+func isInside(dir, path string) bool {
+	rel, err := filepath.Rel(dir, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
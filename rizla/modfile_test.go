@@ -0,0 +1,93 @@
+package rizla
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const testGoMod = `module example.com/svc
+
+go 1.21
+
+require (
+	example.com/shared v0.0.0
+	example.com/remote v1.2.3
+)
+
+replace example.com/shared => ../shared // local
+
+replace (
+	example.com/remote v1.2.3 => example.com/fork v1.2.4
+	example.com/tools => ./tools
+)
+`
+
+const testGoWork = `go 1.21
+
+use (
+	./svc
+	./lib // comment
+)
+
+use "./other"
+`
+
+func TestModDirectives(t *testing.T) {
+	if expected, got := []string{"../shared", "./tools"}, localReplaces([]byte(testGoMod)); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected replaces %v but got %v", expected, got)
+	}
+
+	if expected, got := []string{"./svc", "./lib", "./other"}, workspaceUses([]byte(testGoWork)); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected uses %v but got %v", expected, got)
+	}
+}
+
+func TestLocalModuleDirs(t *testing.T) {
+	root := t.TempDir()
+	for _, dir := range []string{"svc/cmd/api", "svc/tools", "shared", "lib", "other"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	write := func(name, contents string) {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("svc/go.mod", testGoMod)
+	write("go.work", testGoWork)
+
+	t.Setenv("GOWORK", "")
+
+	expected := []string{
+		filepath.Join(root, "shared"),
+		filepath.Join(root, "svc", "tools"),
+		// the svc module itself is not included.
+		filepath.Join(root, "lib"),
+		filepath.Join(root, "other"),
+	}
+
+	if got := localModuleDirs(filepath.Join(root, "svc", "cmd", "api")); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected local modules %v but got %v", expected, got)
+	}
+
+	p := NewProject(filepath.Join(root, "svc", "cmd", "api"))
+	p.Roots = []string{filepath.Join(root, "svc")}
+	p.Watcher = func(dir string) bool {
+		return filepath.Base(dir) != "other"
+	}
+
+	// tools is inside the svc root and other is not accepted by the Watcher.
+	expected = []string{filepath.Join(root, "svc"), filepath.Join(root, "shared"), filepath.Join(root, "lib")}
+	if got := p.watchRoots(); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected watched roots %v but got %v", expected, got)
+	}
+
+	p.DisableLocalModules = true
+	if got := p.watchRoots(); !reflect.DeepEqual(p.Roots, got) {
+		t.Fatalf("expected watched roots %v but got %v", p.Roots, got)
+	}
+}
@@ -0,0 +1,335 @@
+package rizla
+
+import (
+	"bufio"
+	"bytes"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultInclude are the default `Project#Include` patterns, the go files.
+var DefaultInclude = []string{"*.go"}
+
+// DefaultExclude are the default `Project#Exclude` patterns,
+// the ".git", "node_modules" and "vendor" directories.
+var DefaultExclude = []string{".git/", "node_modules/", "vendor/"}
+
+// pattern is a compiled glob pattern of the Include and Exclude lists or of a .gitignore file.
+//
+// The syntax is the one of the .gitignore files:
+//   - a pattern without a slash matches the name of a file or a directory at any depth, i.e "*.go"
+//   - a pattern with a slash matches the relative path, i.e "cmd/*/main.go" or "/bin",
+//     "**" matches zero or more directories, i.e "web/**/*.html"
+//   - a trailing slash matches only directories, i.e "tmp/"
+//   - a leading "!" negates the pattern, the last matching pattern of a list wins.
+type pattern struct {
+	negate   bool
+	dirOnly  bool
+	anchored bool
+	segments []string
+}
+
+// compilePattern compiles a pattern, it returns false for empty patterns and comments.
+func compilePattern(s string) (pattern, bool) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.HasPrefix(s, "#") {
+		return pattern{}, false
+	}
+
+	var pt pattern
+	if strings.HasPrefix(s, "!") {
+		pt.negate = true
+		s = s[1:]
+	} else if strings.HasPrefix(s, `\`) {
+		// escaped "#" or "!".
+		s = s[1:]
+	}
+
+	if strings.HasSuffix(s, "/") {
+		pt.dirOnly = true
+		s = strings.TrimRight(s, "/")
+	}
+
+	if strings.HasPrefix(s, "/") {
+		pt.anchored = true
+		s = strings.TrimLeft(s, "/")
+	}
+
+	if s == "" {
+		return pattern{}, false
+	}
+
+	pt.anchored = pt.anchored || strings.Contains(s, "/")
+	pt.segments = strings.Split(s, "/")
+	return pt, true
+}
+
+// ValidPattern reports whether the "s" is a valid pattern of the `Project#Include` and `Project#Exclude` lists.
+func ValidPattern(s string) bool {
+	pt, ok := compilePattern(s)
+	if !ok {
+		return true // empty patterns are ignored.
+	}
+
+	for _, segment := range pt.segments {
+		if _, err := path.Match(segment, ""); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+// match reports whether the slash separated relative path "rel" matches the pattern, regardless of its negation.
+func (pt pattern) match(rel string, isDir bool) bool {
+	if pt.dirOnly && !isDir {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	if !pt.anchored {
+		ok, _ := path.Match(pt.segments[0], parts[len(parts)-1])
+		return ok
+	}
+
+	return matchSegments(pt.segments, parts)
+}
+
+func matchSegments(segments, parts []string) bool {
+	for len(segments) > 0 {
+		if segments[0] == "**" {
+			segments = segments[1:]
+			if len(segments) == 0 {
+				return true
+			}
+
+			for i := range parts {
+				if matchSegments(segments, parts[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(parts) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(segments[0], parts[0]); !ok {
+			return false
+		}
+
+		segments, parts = segments[1:], parts[1:]
+	}
+
+	return len(parts) == 0
+}
+
+// patterns is a list of compiled patterns.
+type patterns []pattern
+
+func compilePatterns(list []string) patterns {
+	var ps patterns
+	for _, s := range list {
+		if pt, ok := compilePattern(s); ok {
+			ps = append(ps, pt)
+		}
+	}
+	return ps
+}
+
+// match reports whether the "rel" path matches the list, the last matching pattern wins.
+func (ps patterns) match(rel string, isDir bool) bool {
+	matched := false
+	for _, pt := range ps {
+		if pt.match(rel, isDir) {
+			matched = !pt.negate
+		}
+	}
+	return matched
+}
+
+// excludes reports whether the "rel" path or any of its parent directories matches the list,
+// like git, a path can't be re-included if one of its parent directories is excluded.
+func (ps patterns) excludes(rel string, isDir bool) bool {
+	if len(ps) == 0 {
+		return false
+	}
+
+	parts := strings.Split(rel, "/")
+	for i := 1; i <= len(parts); i++ {
+		if ps.match(strings.Join(parts[:i], "/"), i < len(parts) || isDir) {
+			return true
+		}
+	}
+	return false
+}
+
+// gitignoreFile is a parsed .gitignore file.
+type gitignoreFile struct {
+	modTime  time.Time
+	patterns patterns
+}
+
+// gitignores caches the .gitignore files of a project's tree,
+// a file is parsed again when its modification time changes.
+// The modification times are checked once after each `refresh`, i.e once per snapshot of the tree.
+type gitignores struct {
+	mu    sync.Mutex
+	files map[string]*gitignoreFile
+	// checked the directories which their .gitignore file is checked since the last refresh.
+	checked map[string]bool
+}
+
+// refresh marks the .gitignore files to be checked again on their next load.
+func (g *gitignores) refresh() {
+	g.mu.Lock()
+	g.checked = nil
+	g.mu.Unlock()
+}
+
+// load returns the patterns of the .gitignore file of the "dir", if any.
+func (g *gitignores) load(dir string) patterns {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if g.checked[dir] {
+		if f, ok := g.files[dir]; ok {
+			return f.patterns
+		}
+		return nil
+	}
+
+	if g.checked == nil {
+		g.checked = make(map[string]bool)
+	}
+	g.checked[dir] = true
+
+	filename := filepath.Join(dir, ".gitignore")
+	info, err := os.Stat(filename)
+	if err != nil {
+		delete(g.files, dir)
+		return nil
+	}
+
+	if f, ok := g.files[dir]; ok && f.modTime.Equal(info.ModTime()) {
+		return f.patterns
+	}
+
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		delete(g.files, dir)
+		return nil
+	}
+
+	f := &gitignoreFile{modTime: info.ModTime()}
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		if pt, ok := compilePattern(scanner.Text()); ok {
+			f.patterns = append(f.patterns, pt)
+		}
+	}
+
+	if g.files == nil {
+		g.files = make(map[string]*gitignoreFile)
+	}
+	g.files[dir] = f
+	return f.patterns
+}
+
+// ignores reports whether the "rel" path, relative to the "root", is ignored
+// by any of the .gitignore files from the "root" down to the path's directory.
+func (g *gitignores) ignores(root, rel string, isDir bool) bool {
+	parts := strings.Split(rel, "/")
+	dir := root
+	for i := range parts {
+		if g.load(dir).excludes(strings.Join(parts[i:], "/"), isDir) {
+			return true
+		}
+		dir = filepath.Join(dir, parts[i])
+	}
+	return false
+}
+
+// relPath returns the root which contains the "abs" path and the slash separated path relative to it,
+// the root is empty and the path is the base name if none of the project's roots contains it.
+func (p *Project) relPath(abs string) (root, rel string) {
+	for _, roots := range [][]string{p.roots(), p.moduleRoots} {
+		for _, r := range roots {
+			if isInside(r, abs) && len(r) > len(root) {
+				root = r
+			}
+		}
+	}
+
+	if root == "" {
+		return "", filepath.Base(abs)
+	}
+
+	rel, err := filepath.Rel(root, abs)
+	if err != nil {
+		return "", filepath.Base(abs)
+	}
+	return root, filepath.ToSlash(rel)
+}
+
+// excluded reports whether the "abs" path is excluded by the project's Exclude patterns or .gitignore files.
+func (p *Project) excluded(abs string, isDir bool) bool {
+	root, rel := p.relPath(abs)
+	if rel == "." {
+		// the roots are always watched.
+		return false
+	}
+
+	if p.patterns().exclude.excludes(rel, isDir) {
+		return true
+	}
+
+	return root != "" && !p.DisableGitignore && p.gitignores.ignores(root, rel, isDir)
+}
+
+// projectPatterns are the compiled Include and Exclude patterns of a project.
+type projectPatterns struct {
+	include patterns
+	exclude patterns
+}
+
+// patterns returns the compiled Include and Exclude patterns of the project,
+// they are compiled on the first call and each time the project starts running, see `loadPatterns`.
+func (p *Project) patterns() *projectPatterns {
+	if ps := p.compiledPatterns.Load(); ps != nil {
+		return ps
+	}
+	return p.loadPatterns()
+}
+
+// loadPatterns compiles the Include and Exclude patterns of the project.
+func (p *Project) loadPatterns() *projectPatterns {
+	include, exclude := p.Include, p.Exclude
+	if include == nil {
+		include = DefaultInclude
+	}
+	if exclude == nil {
+		exclude = DefaultExclude
+	}
+
+	ps := &projectPatterns{include: compilePatterns(include), exclude: compilePatterns(exclude)}
+	p.compiledPatterns.Store(ps)
+	return ps
+}
+
+// watchDir is the default Watcher of a project,
+// it accepts the directories which are not excluded.
+func (p *Project) watchDir(abs string) bool {
+	return !p.excluded(abs, true)
+}
+
+// matchFile is the default Matcher of a project,
+// it accepts the files which match the Include patterns and they are not excluded.
+func (p *Project) matchFile(abs string) bool {
+	_, rel := p.relPath(abs)
+	return p.patterns().include.match(rel, false) && !p.excluded(abs, false)
+}
@@ -0,0 +1,130 @@
+package rizla
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestPatterns(t *testing.T) {
+	ps := compilePatterns([]string{"*.go", "!*_test.go", "web/**/*.html", "/root.tmpl", "# comment", ""})
+
+	tests := []struct {
+		rel     string
+		matched bool
+	}{
+		{"main.go", true},
+		{"cmd/api/main.go", true},
+		{"cmd/api/main_test.go", false},
+		{"main.gooutput", false},
+		{"web/index.html", true},
+		{"web/admin/users/list.html", true},
+		{"index.html", false},
+		{"root.tmpl", true},
+		{"views/root.tmpl", false},
+	}
+
+	for _, tt := range tests {
+		if got := ps.match(tt.rel, false); got != tt.matched {
+			t.Fatalf("expected %s to match: %v but got %v", tt.rel, tt.matched, got)
+		}
+	}
+
+	dirs := compilePatterns([]string{"vendor/", "tmp"})
+	if !dirs.excludes("vendor/example.com/x/x.go", false) {
+		t.Fatal("expected the files of an excluded directory to be excluded")
+	}
+	if dirs.excludes("vendor.go", false) || dirs.excludes("cmd/vendor", false) {
+		t.Fatal("expected a pattern with a trailing slash to match only directories")
+	}
+	if !dirs.excludes("cmd/tmp", false) || !dirs.excludes("cmd/tmp", true) {
+		t.Fatal("expected a pattern without a trailing slash to match files and directories")
+	}
+
+	for _, s := range []string{"*.go", "web/**/*.html", "!vendor/"} {
+		if !ValidPattern(s) {
+			t.Fatalf("expected %s to be a valid pattern", s)
+		}
+	}
+	if ValidPattern("web/[a-") {
+		t.Fatal("expected an invalid pattern")
+	}
+}
+
+func TestProjectGitignore(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, contents string) string {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return filename
+	}
+
+	write(".gitignore", "# generated\n/bin/\n*.gen.go\n")
+	write("internal/.gitignore", "mocks/\n")
+
+	p := newTestProject()
+	p.dir = root
+
+	tests := []struct {
+		name    string
+		isDir   bool
+		matched bool
+	}{
+		{"main.go", false, true},
+		{"model.gen.go", false, false},
+		{"bin", true, false},
+		{"cmd/bin", true, true},
+		{"internal/mocks", true, false},
+		{"internal/mocks/store.go", false, false},
+		{"mocks", true, true},
+		{".git", true, false},
+	}
+
+	for _, tt := range tests {
+		abs := filepath.Join(root, filepath.FromSlash(tt.name))
+		var got bool
+		if tt.isDir {
+			got = p.Watcher(abs)
+		} else {
+			got = p.Matcher(abs)
+		}
+
+		if got != tt.matched {
+			t.Fatalf("expected %s to be accepted: %v but got %v", tt.name, tt.matched, got)
+		}
+	}
+
+	// a change of a .gitignore file is seen on the next snapshot, not on every match.
+	genFile := filepath.Join(root, "model.gen.go")
+	gitignore := write(".gitignore", "/bin/\n")
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(gitignore, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	if p.Matcher(genFile) {
+		t.Fatal("expected the .gitignore file to be checked once per snapshot")
+	}
+
+	takeSnapshot(p, nil)
+	if !p.Matcher(genFile) {
+		t.Fatal("expected the changed .gitignore file to be loaded again after a snapshot")
+	}
+
+	write(".gitignore", "*.gen.go\n")
+	takeSnapshot(p, nil)
+	if p.Matcher(genFile) {
+		t.Fatalf("expected %s to be ignored", genFile)
+	}
+
+	p.DisableGitignore = true
+	if !p.Matcher(genFile) {
+		t.Fatal("expected the .gitignore files to be ignored when DisableGitignore is true")
+	}
+}
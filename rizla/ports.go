@@ -0,0 +1,68 @@
+package rizla
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"time"
+)
+
+// DefaultPortsTimeout is the default maximum time to wait
+// for the ports of a stopped program to be released.
+var DefaultPortsTimeout = 10 * time.Second
+
+// portFree reports whether the TCP "port" can be listened on.
+func portFree(port int) bool {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+// waitPorts waits for the TCP "ports" to be released,
+// it returns an error if any of them is still in use after the "timeout".
+func waitPorts(ports []int, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+
+	for _, port := range ports {
+		for !portFree(port) {
+			if time.Now().After(deadline) {
+				return fmt.Errorf("port %d is still in use after %s", port, timeout)
+			}
+			time.Sleep(50 * time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+func (p *Project) portsTimeout() time.Duration {
+	if p.PortsTimeout <= 0 {
+		return DefaultPortsTimeout
+	}
+	return p.PortsTimeout
+}
+
+// stopProject stops the running program of the project
+// and waits for its ports, if any, to be released.
+func stopProject(p *Project) error {
+	ports := append([]int(nil), p.Ports...)
+	if p.DetectPorts && p.proc != nil && p.proc.alive() {
+		ports = append(ports, listeningPorts(p.proc.cmd.Process.Pid)...)
+	}
+
+	if err := killProcess(p); err != nil {
+		return err
+	}
+
+	if len(ports) > 0 {
+		if err := waitPorts(ports, p.portsTimeout()); err != nil {
+			// try to start the new program anyway.
+			p.Err.Warnf("%s%v", p.logPrefix(), err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package rizla
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// listeningPorts returns the TCP ports which the process of "pid"
+// or any other process of its process group are listening on.
+// The ports are detected through the /proc file system.
+func listeningPorts(pid int) []int {
+	entries, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil
+	}
+
+	// the inodes of the sockets which the processes have opened.
+	inodes := make(map[string]bool)
+	for _, entry := range entries {
+		n, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+
+		if n != pid && processGroup(n) != pid {
+			continue
+		}
+
+		fdDir := filepath.Join("/proc", entry.Name(), "fd")
+		fds, _ := os.ReadDir(fdDir)
+		for _, fd := range fds {
+			link, err := os.Readlink(filepath.Join(fdDir, fd.Name()))
+			if err == nil && strings.HasPrefix(link, "socket:[") {
+				inodes[link[len("socket:["):len(link)-1]] = true
+			}
+		}
+	}
+
+	if len(inodes) == 0 {
+		return nil
+	}
+
+	var ports []int
+	seen := make(map[int]bool)
+	for _, table := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		for _, port := range listeningSocketPorts(table, inodes) {
+			if !seen[port] {
+				seen[port] = true
+				ports = append(ports, port)
+			}
+		}
+	}
+
+	return ports
+}
+
+// processGroup returns the process group id of the process of "pid" or -1.
+func processGroup(pid int) int {
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return -1
+	}
+
+	// pid (comm) state ppid pgrp ..., the comm may contain spaces.
+	stat := string(b)
+	fields := strings.Fields(stat[strings.LastIndexByte(stat, ')')+1:])
+	if len(fields) < 3 {
+		return -1
+	}
+
+	pgrp, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return -1
+	}
+	return pgrp
+}
+
+// tcpListen is the state of a listening socket on the /proc/net/tcp tables.
+const tcpListen = "0A"
+
+// listeningSocketPorts returns the local ports of the listening sockets of the /proc/net/tcp "table"
+// which their inode is one of the "inodes".
+func listeningSocketPorts(table string, inodes map[string]bool) []int {
+	f, err := os.Open(table)
+	if err != nil {
+		return nil
+	}
+	defer f.Close()
+
+	var ports []int
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // skip the header.
+	for scanner.Scan() {
+		// sl local_address rem_address st tx_queue:rx_queue tr:tm->when retrnsmt uid timeout inode
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 10 || fields[3] != tcpListen || !inodes[fields[9]] {
+			continue
+		}
+
+		colonIdx := strings.LastIndexByte(fields[1], ':')
+		port, err := strconv.ParseInt(fields[1][colonIdx+1:], 16, 32)
+		if err != nil {
+			continue
+		}
+		ports = append(ports, int(port))
+	}
+
+	return ports
+}
@@ -0,0 +1,8 @@
+//go:build !linux
+
+package rizla
+
+// listeningPorts returns nil, the ports detection is supported only on linux.
+func listeningPorts(pid int) []int {
+	return nil
+}
@@ -0,0 +1,71 @@
+package rizla
+
+import (
+	"net"
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func listenTestPort(t *testing.T) (net.Listener, int) {
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ln, ln.Addr().(*net.TCPAddr).Port
+}
+
+func TestWaitPorts(t *testing.T) {
+	ln, port := listenTestPort(t)
+
+	if err := waitPorts([]int{port}, 200*time.Millisecond); err == nil {
+		ln.Close()
+		t.Fatalf("expected an error as port %d is still in use", port)
+	}
+
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		ln.Close()
+	}()
+
+	if err := waitPorts([]int{port}, 5*time.Second); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestStopProjectPortsTimeout(t *testing.T) {
+	ln, port := listenTestPort(t)
+	go func() {
+		time.Sleep(200 * time.Millisecond)
+		ln.Close()
+	}()
+
+	p := newTestProject()
+	p.Ports = []int{port}
+	p.PortsTimeout = 0 // the DefaultPortsTimeout.
+	if err := stopProject(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !portFree(port) {
+		t.Fatalf("expected port %d to be released when the program is stopped", port)
+	}
+}
+
+func TestListeningPorts(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("ports detection is supported only on linux")
+	}
+
+	ln, port := listenTestPort(t)
+	defer ln.Close()
+
+	for _, got := range listeningPorts(os.Getpid()) {
+		if got == port {
+			return
+		}
+	}
+
+	t.Fatalf("expected port %d to be detected", port)
+}
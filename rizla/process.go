@@ -0,0 +1,166 @@
+package rizla
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+// process is a running instance of a project's program.
+type process struct {
+	cmd *exec.Cmd
+	// done is closed when the process has exited.
+	done chan struct{}
+	// err is the error of the cmd.Wait, valid after the done is closed.
+	err error
+	// outputMatched is closed when a line of the program's output matches the project's Ready.Output,
+	// nil if it's not set.
+	outputMatched chan struct{}
+	// stopping is set to 1 when rizla stops the process, see `stoppedByRizla`.
+	stopping int32
+	// started the time the process was started.
+	started time.Time
+}
+
+// startProcess starts the "cmd" and waits for its exit in the background.
+// The "cmd" is started in its own process group, see `killProcess`.
+func startProcess(cmd *exec.Cmd) (*process, error) {
+	setProcessGroup(cmd)
+	if err := cmd.Start(); err != nil {
+		return nil, err
+	}
+
+	proc := &process{
+		cmd:     cmd,
+		done:    make(chan struct{}),
+		started: time.Now(),
+	}
+
+	go func() {
+		proc.err = cmd.Wait()
+		close(proc.done)
+	}()
+
+	return proc, nil
+}
+
+// newGroupCommand returns a command which runs in its own process group,
+// the whole group is killed when the "ctx" is canceled, i.e by a newer change,
+// so a canceled build, stage or hook doesn't leave its children running.
+func newGroupCommand(ctx context.Context, name string, args ...string) *exec.Cmd {
+	cmd := exec.CommandContext(ctx, name, args...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		return killGroup(cmd)
+	}
+	return cmd
+}
+
+// exited reports whether the process has exited.
+func (proc *process) exited() bool {
+	select {
+	case <-proc.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// stoppedByRizla reports whether the process is stopped, or it's being stopped, by rizla,
+// i.e for a reload, instead of its own exit.
+func (proc *process) stoppedByRizla() bool {
+	return atomic.LoadInt32(&proc.stopping) == 1
+}
+
+// crashed reports whether the exited process has failed, with a non-zero exit code or by a signal.
+func (proc *process) crashed() bool {
+	state := proc.cmd.ProcessState
+	return state == nil || !state.Success()
+}
+
+// exitStatus returns the exit code or the signal of the exited process, i.e "exit status 2" or "signal: killed".
+func (proc *process) exitStatus() string {
+	if state := proc.cmd.ProcessState; state != nil {
+		return state.String()
+	}
+	if proc.err != nil {
+		return proc.err.Error()
+	}
+	return "unknown exit status"
+}
+
+// kill kills the process.
+func (proc *process) kill() error {
+	err := proc.cmd.Process.Kill()
+	if err != nil && isWindows {
+		// force kill, sometimes proc.Kill or Signal(os.Kill) doesn't kills
+		err = exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(proc.cmd.Process.Pid)).Run()
+		if err != nil && err.Error() == "exit status 128" {
+			err = nil // skip that stupid error here.
+		}
+	}
+	return err
+}
+
+// alive reports whether the process or any other process of its process group is still running.
+func (proc *process) alive() bool {
+	return !proc.exited() || proc.groupAlive()
+}
+
+func (p *Project) stopTimeout() time.Duration {
+	if p.StopTimeout <= 0 {
+		return DefaultStopTimeout
+	}
+	return p.StopTimeout
+}
+
+// killProcess stops the running program of the project, if any.
+//
+// It sends the project's StopSignal to the program's process group and waits up to the StopTimeout
+// for all of its processes to exit, if any of them is still running after that, then the whole group is killed.
+// Only the processes which rizla started, and their children, are signaled.
+func killProcess(p *Project) error {
+	proc := p.proc
+	if proc == nil || !proc.alive() {
+		return nil
+	}
+
+	started := time.Now()
+	atomic.StoreInt32(&proc.stopping, 1)
+
+	sig := p.StopSignal
+	if sig == nil || isWindows {
+		// windows doesn't support signals other than os.Kill.
+		sig = os.Kill
+	}
+
+	if err := proc.signalGroup(sig); err != nil && proc.alive() {
+		return err
+	}
+
+	stopTimeout := p.stopTimeout()
+	timer := time.NewTimer(stopTimeout)
+	defer timer.Stop()
+	ticker := time.NewTicker(50 * time.Millisecond)
+	defer ticker.Stop()
+
+wait:
+	for proc.alive() {
+		select {
+		case <-ticker.C:
+		case <-timer.C:
+			p.Err.Warnf("%sProgram did not exit in %s after %v, killing it...", p.logPrefix(), stopTimeout, sig)
+			if err := proc.signalGroup(os.Kill); err != nil && proc.alive() {
+				return err
+			}
+			<-proc.done
+			break wait
+		}
+	}
+
+	p.Out.Infof("%sProgram stopped in %s", p.logPrefix(), time.Since(started).Round(time.Millisecond))
+	return nil
+}
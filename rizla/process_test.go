@@ -0,0 +1,76 @@
+package rizla
+
+import (
+	"io"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func newTestProject() *Project {
+	p := NewProject("process_test.go")
+	p.Out.SetOutput(io.Discard)
+	p.Err.SetOutput(io.Discard)
+	return p
+}
+
+func startTestProcess(t *testing.T, p *Project, script string) {
+	if isWindows {
+		t.Skip("signals are not supported on windows")
+	}
+
+	proc, err := startProcess(exec.Command("sh", "-c", script))
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.proc = proc
+}
+
+func TestKillProcessGraceful(t *testing.T) {
+	p := newTestProject()
+	p.StopTimeout = 0 // the DefaultStopTimeout.
+	startTestProcess(t, p, "trap 'exit 0' TERM; while :; do sleep 0.1; done")
+
+	// give the shell the time to install its trap.
+	time.Sleep(200 * time.Millisecond)
+
+	started := time.Now()
+	if err := killProcess(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.proc.exited() {
+		t.Fatal("expected the process to be exited")
+	}
+
+	if elapsed := time.Since(started); elapsed >= DefaultStopTimeout {
+		t.Fatalf("expected the process to exit on %v before the timeout but it took %s", p.StopSignal, elapsed)
+	}
+
+	if p.proc.err != nil {
+		t.Fatalf("expected a clean exit but got %v", p.proc.err)
+	}
+}
+
+func TestKillProcessTimeout(t *testing.T) {
+	p := newTestProject()
+	p.StopSignal = syscall.SIGINT
+	p.StopTimeout = 300 * time.Millisecond
+	startTestProcess(t, p, "trap '' INT; while :; do sleep 0.1; done")
+
+	time.Sleep(200 * time.Millisecond)
+
+	if err := killProcess(p); err != nil {
+		t.Fatal(err)
+	}
+
+	if !p.proc.exited() {
+		t.Fatal("expected the process to be killed after the stop timeout")
+	}
+
+	// killing an exited process is a no-op.
+	if err := killProcess(p); err != nil {
+		t.Fatal(err)
+	}
+}
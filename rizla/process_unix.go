@@ -0,0 +1,51 @@
+//go:build !windows
+
+package rizla
+
+import (
+	"os"
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup makes the "cmd" to start in its own process group,
+// so the program and all of its children can be signaled together.
+func setProcessGroup(cmd *exec.Cmd) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = new(syscall.SysProcAttr)
+	}
+	cmd.SysProcAttr.Setpgid = true
+}
+
+// signalGroup sends the "sig" to the process group of the process.
+func (proc *process) signalGroup(sig os.Signal) error {
+	s, ok := sig.(syscall.Signal)
+	if !ok {
+		return proc.cmd.Process.Signal(sig)
+	}
+
+	err := syscall.Kill(-proc.cmd.Process.Pid, s)
+	if err == syscall.ESRCH {
+		if !proc.exited() {
+			// the process is not a group leader, signal just the process.
+			return proc.cmd.Process.Signal(sig)
+		}
+		// the group has no processes left.
+		return nil
+	}
+	return err
+}
+
+// groupAlive reports whether any process of the process group is still running.
+func (proc *process) groupAlive() bool {
+	return syscall.Kill(-proc.cmd.Process.Pid, 0) == nil
+}
+
+// killGroup kills the process group of the started "cmd", see `setProcessGroup`.
+func killGroup(cmd *exec.Cmd) error {
+	if err := syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL); err != syscall.ESRCH {
+		return err
+	}
+	// not a group leader.
+	return cmd.Process.Kill()
+}
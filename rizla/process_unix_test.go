@@ -0,0 +1,106 @@
+//go:build !windows
+
+package rizla
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// spawnerProgram starts a child process and writes its pid to the file of its first argument.
+const spawnerProgram = `package main
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+)
+
+func main() {
+	cmd := exec.Command("sleep", "60")
+	if err := cmd.Start(); err != nil {
+		panic(err)
+	}
+	os.WriteFile(os.Args[1], []byte(strconv.Itoa(cmd.Process.Pid)), 0644)
+	time.Sleep(time.Hour)
+}
+`
+
+func readPidFile(t *testing.T, filename string) int {
+	deadline := time.Now().Add(30 * time.Second)
+	for time.Now().Before(deadline) {
+		if b, err := os.ReadFile(filename); err == nil && len(b) > 0 {
+			pid, err := strconv.Atoi(string(b))
+			if err != nil {
+				t.Fatal(err)
+			}
+			return pid
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	t.Fatalf("pid file %s was not written in time", filename)
+	return 0
+}
+
+// processGone reports whether the process of "pid" does not exist or it's a zombie.
+func processGone(pid int) bool {
+	if syscall.Kill(pid, 0) == syscall.ESRCH {
+		return true
+	}
+
+	b, err := os.ReadFile(filepath.Join("/proc", strconv.Itoa(pid), "stat"))
+	if err != nil {
+		return !isMac // can't tell on systems without /proc.
+	}
+	// pid (comm) state ...
+	fields := strings.Fields(string(b[strings.LastIndexByte(string(b), ')')+1:]))
+	return len(fields) > 0 && fields[0] == "Z"
+}
+
+func TestReloadKillsGrandchildren(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping the build of a program in short mode")
+	}
+
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep is not available")
+	}
+
+	p := newTestProgram(t, spawnerProgram)
+	pidFile := filepath.Join(p.dir, "child.pid")
+	p.Args = []string{pidFile}
+	// the change is fired without a content change.
+	p.DisableContentHash = true
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+
+	firstChild := readPidFile(t, pidFile)
+	os.Remove(pidFile)
+
+	w.change(p, p.MainFile)
+	secondChild := readPidFile(t, pidFile)
+
+	if !processGone(firstChild) {
+		syscall.Kill(firstChild, syscall.SIGKILL)
+		t.Fatalf("the child process %d of the first run survived the reload", firstChild)
+	}
+
+	r.Stop()
+	waitRunError(t, errCh)
+
+	if !processGone(secondChild) {
+		syscall.Kill(secondChild, syscall.SIGKILL)
+		t.Fatalf("the child process %d of the second run survived the stop", secondChild)
+	}
+}
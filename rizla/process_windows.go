@@ -0,0 +1,34 @@
+package rizla
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+)
+
+// setProcessGroup is a no-op on windows,
+// the `killGroup` uses the taskkill /T which kills the whole process tree.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup sends the "sig" to the process,
+// windows doesn't support signals other than os.Kill, which kills the whole process tree.
+func (proc *process) signalGroup(sig os.Signal) error {
+	if sig == os.Kill {
+		if err := killGroup(proc.cmd); err != nil {
+			// i.e the taskkill is not available, kill the process at least.
+			return proc.kill()
+		}
+		return nil
+	}
+	return proc.cmd.Process.Signal(sig)
+}
+
+// groupAlive reports whether the process is still running.
+func (proc *process) groupAlive() bool {
+	return !proc.exited()
+}
+
+// killGroup kills the process tree of the started "cmd".
+func killGroup(cmd *exec.Cmd) error {
+	return exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(cmd.Process.Pid)).Run()
+}
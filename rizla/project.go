@@ -1,10 +1,13 @@
 package rizla
 
 import (
+	"errors"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
+	"syscall"
 	"time"
 
 	"github.com/kataras/golog"
@@ -12,6 +15,10 @@ import (
 
 const minimumAllowReloadAfter = time.Duration(2) * time.Second
 
+// DefaultStopTimeout is the default time to wait for a program to exit
+// after its StopSignal has been sent and before it's killed.
+var DefaultStopTimeout = 5 * time.Second
+
 // DefaultDisableProgramRerunOutput a long name but, it disables the output of the program's 'messages' after the first successfully run for each of the projects
 // the project iteral can be override this value.
 // set to true to disable the program's output when reloads
@@ -20,14 +27,14 @@ var DefaultDisableProgramRerunOutput = false
 // MatcherFunc returns whether the file should be watched for the reload
 type MatcherFunc func(string) bool
 
-// DefaultGoMatcher is the default Matcher for the Project iteral
+// DefaultGoMatcher accepts the go files, the ones with the ".go" extension.
+// The default Matcher of the Project iteral is based on its Include and Exclude patterns.
 func DefaultGoMatcher(fullname string) bool {
-	return (filepath.Ext(fullname) == goExt) ||
-		(!isWindows && strings.Contains(fullname, goExt))
+	return filepath.Ext(fullname) == goExt
 }
 
-// DefaultWatcher is the default Watcher for the Project iteral
-// allows all subdirs except .git, node_modules and vendor
+// DefaultWatcher allows all subdirs except .git, node_modules and vendor.
+// The default Watcher of the Project iteral is based on its Exclude patterns.
 func DefaultWatcher(abs string) bool {
 	base := filepath.Base(abs)
 	// by-default ignore .git folder, node_modules, vendor and any hidden files.
@@ -38,41 +45,19 @@ func DefaultWatcher(abs string) bool {
 // on windows, it will just execute that based on the operating system, nothing crazy here,
 // they are filled by the cli but they can be customized by the source as well.
 //
-// If contains whitespaces, after the first whitespace they are the command's flags (if not a script file).
+// They run before the reload of each project, before its OnReloadHooks, as hooks with the default policy and timeout,
+// their arguments are split by the rules of `SplitCommand`, see `Hook` too.
 var OnReloadScripts []string
 
-// DefaultOnReload fired when file has changed and reload going to happens
-func DefaultOnReload(p *Project) func(string) {
-	return func(string) {
-		fromproject := ""
-		if p.Name != "" {
-			fromproject = "From project '" + p.Name + "': "
-		}
-		p.Out.Infof("%sA change has been detected, reloading now...", fromproject)
-
-		if len(OnReloadScripts) > 0 {
-			p.Out.Infof("%sExecuting commands from %s before restart...", fromproject, strings.Join(OnReloadScripts, ", "))
-			for _, s := range OnReloadScripts {
-
-				// The below should work for things like
-				// service supervisor restart
-				nameAndFlags := strings.Split(s, " ")
-				var args []string
-				name := nameAndFlags[0]
-				if len(nameAndFlags) > 1 {
-					args = nameAndFlags[1:]
-				}
-
-				cmd := exec.Command(name, args...)
-				cmd.Stderr = p.Err.Printer.Output
-				cmd.Stdout = p.Out.Printer.Output
-				if err := cmd.Run(); err != nil {
-					p.Out.Errorf("%s%s run: %v", fromproject, s, err)
-					os.Exit(1)
-				}
-				cmd.Wait() // ignore error.
-			}
-		}
+// OnReloadedScripts same as the `OnReloadScripts` but they run after the reload of each project,
+// when its new program has started, or when it's ready if the project has a Ready check,
+// before its OnReloadedHooks, i.e to warm caches or to notify other tools.
+var OnReloadedScripts []string
+
+// DefaultOnReload fired when files have changed and reload going to happens
+func DefaultOnReload(p *Project) func([]ChangeEvent) {
+	return func([]ChangeEvent) {
+		p.Out.Infof("%sA change has been detected, reloading now...", p.logPrefix())
 	}
 }
 
@@ -80,8 +65,8 @@ func DefaultOnReload(p *Project) func(string) {
 
 // DefaultOnReloaded fired when reload has been finished.
 // Defaults to noOp.
-func DefaultOnReloaded(p *Project) func(string) {
-	return func(string) {
+func DefaultOnReloaded(p *Project) func([]ChangeEvent) {
+	return func([]ChangeEvent) {
 		// p.Out.Printer.Output.Write(rdy)
 	}
 }
@@ -90,34 +75,110 @@ func DefaultOnReloaded(p *Project) func(string) {
 type Project struct {
 	// optional Name for the project
 	Name string
-	// MainFile is the absolute path of the go project's main file source.
+	// MainFile is the absolute path of the go project's main file source,
+	// empty if the project was created by its package, see `NewProject`.
 	MainFile string
 	// The application's name, usually is the MainFile withotu the extension.
 	// At the future we may provide a way for custom naming which will be used on the "go build -o" flag.
 	AppName string
 	Args    []string
+	// WorkDir is the working directory of the program.
+	// Defaults to the directory of the main package.
+	WorkDir string
+	// Roots are the directories which are watched, including their subdirectories,
+	// i.e the module's root directory when the main package lives in a subdirectory of it.
+	// Defaults to the directory of the main package.
+	Roots []string
+	// DisableLocalModules set to true to not watch the local modules which the project depends on,
+	// the directories of the local replace directives of its go.mod and the modules of its go.work file.
+	// Defaults to false.
+	DisableLocalModules bool
+	// WatchImportsOnly set to true to reload only when a file of a package which the main package imports,
+	// or a file which they embed (//go:embed), changes.
+	// The imports are computed by the "go list -deps" command on start
+	// and they are computed again after each reload caused by a go file or a go.mod change.
+	// Defaults to false, any matched file under the Roots fires a reload.
+	WatchImportsOnly bool
 	// The Output destination (sent by rizla and your program)
 	Out *golog.Logger
 	// The Err Output destination (sent on rizla errors and your program's errors)
 	Err *golog.Logger
+	// Include are the glob patterns of the files which fire a reload on change,
+	// i.e []string{"*.go", "*.tmpl", "web/**/*.html"}, see `DefaultInclude`.
+	// Patterns without a slash match the file's name, the rest match the path relative to its root
+	// and "**" matches zero or more directories. A leading "!" negates a pattern, the last matching pattern wins.
+	// Defaults to `DefaultInclude`, the go files.
+	Include []string
+	// Exclude are the glob patterns of the files and directories which are ignored,
+	// an excluded directory is not watched at all. A trailing slash matches only directories,
+	// i.e []string{".git/", "node_modules/", "vendor/", "*_test.go"}.
+	// The syntax is the same as the Include's one.
+	// Defaults to `DefaultExclude`.
+	Exclude []string
+	// DisableGitignore set to true to not ignore the files and directories which are ignored by the .gitignore files
+	// of the watched directories.
+	// Defaults to false.
+	DisableGitignore bool
 	// Watcher accepts subdirectories by the watcher
 	// executes before the watcher starts,
 	// if return true, then this (absolute) subdirectory is watched by watcher
-	// the default accepts all subdirectories but ignores the ".git", "node_modules" and "vendor"
+	// the default accepts all subdirectories which are not excluded by the Exclude patterns or the .gitignore files.
 	Watcher MatcherFunc
+	// Matcher accepts the files which fire a reload on change,
+	// the default accepts the files which match the Include patterns
+	// and they are not excluded by the Exclude patterns or the .gitignore files.
 	Matcher MatcherFunc
-	// AllowReloadAfter skip reload on file changes that made too fast from the last reload
-	// minimum allowed duration is 3 seconds.
+	// AllowReloadAfter is the minimum duration between two reloads,
+	// the changes that are made too fast from the last reload are not skipped,
+	// the reload happens when this duration has passed.
+	// Defaults to 2 seconds.
 	AllowReloadAfter time.Duration
 	// AllowRunAfter it accepts the file changes
-	// but it waits "x" duration for the reload to happen.
+	// but it waits "x" duration, after the last change, for the reload to happen.
+	// It's the quiet window of the reload when it's bigger than the Debounce.
 	AllowRunAfter time.Duration
-	// OnReload fires when when file has been changed and rizla is going to reload the project
-	// the parameter is the changed file name
-	OnReload func(string)
-	// OnReloaded fires when rizla finish with the reload
-	// the parameter is the changed file name
-	OnReloaded func(string)
+	// Debounce is the quiet window of the reload, the changes are collected
+	// until no change is made for that duration, then the project is reloaded once with all of them.
+	// Changes that are made while a reload is in progress fire one more reload after it.
+	// Defaults to `DefaultDebounce`.
+	Debounce time.Duration
+	// TriggerOps are the operations of the changes which fire a reload, i.e Create | Write.
+	// Defaults to `DefaultTriggerOps`.
+	TriggerOps Op
+	// DisableContentHash set to true to reload even if the content of the changed files
+	// is the same as the last build, i.e on a touch or on a save without modifications.
+	// Defaults to false.
+	DisableContentHash bool
+	// OnReload fires when when files have been changed and rizla is going to reload the project
+	// the parameter is the changes, one per file
+	OnReload func(changes []ChangeEvent)
+	// OnReloadHooks are commands which run in order after the OnReload and before the reload,
+	// after the `OnReloadScripts`. A failed one stops the reload, it's reported or it stops rizla, see `Hook#Policy`.
+	OnReloadHooks []Hook
+	// OnReloaded fires when rizla finish with the reload, when the new program is ready, see Ready.
+	// the parameter is the changes, one per file
+	OnReloaded func(changes []ChangeEvent)
+	// OnReloadedHooks are commands which run in order after the OnReloaded, when the new program is ready,
+	// after the `OnReloadedScripts`. The reload is already done, so the HookAbort policy is the same as the HookContinue.
+	OnReloadedHooks []Hook
+	// Ready describes when a started program is ready, i.e when it responds to an HTTP request.
+	// The reload is finished when the new program is ready, if it's not ready in time
+	// or it exits before that, the failure is reported and the OnReloaded and the OnReloadedHooks don't fire.
+	// Defaults to nil, the program is ready when it's started.
+	Ready *ReadyCheck
+	// RestartOnCrash set to true to start the program again when it crashes,
+	// when it exits with a non-zero exit code or by a signal which rizla didn't send.
+	// The restarts are delayed by an exponential backoff, see RestartBackoff and MaxRestarts.
+	// Defaults to false, the crash is reported and the next change starts the program again.
+	RestartOnCrash bool
+	// RestartBackoff is the delay of the first restart after a crash,
+	// it doubles on each consecutive crash up to the `DefaultMaxRestartBackoff`.
+	// Defaults to `DefaultRestartBackoff`.
+	RestartBackoff time.Duration
+	// MaxRestarts is the maximum number of consecutive restarts, after that the next change starts the program again.
+	// The count starts over on a reload and when the program crashes after it has run for a minute.
+	// Defaults to `DefaultMaxRestarts`, a negative value means no limit.
+	MaxRestarts int
 	// DisableRuntimeDir set to true to disable adding subdirectories into the watcher, when a folder created at runtime
 	// set to true to disable the program's output when reloads
 	// defaults to false
@@ -125,28 +186,129 @@ type Project struct {
 	// DisableProgramRerunOutput a long name but, it disables the output of the program's 'messages' after the first successfully run
 	// defaults to false
 	DisableProgramRerunOutput bool
+	// StopSignal is the signal which is sent to the program in order to stop it gracefully,
+	// i.e before a reload.
+	// Defaults to SIGTERM. On windows the program is always killed, signals are not supported there.
+	StopSignal os.Signal
+	// StopTimeout is the time to wait for the program to exit after the StopSignal has been sent,
+	// if the program is still running after that, then it's killed.
+	// Defaults to `DefaultStopTimeout`.
+	StopTimeout time.Duration
+	// Ports are the TCP ports which the program listens on,
+	// after the program is stopped, rizla waits for them to be released before it starts the new one.
+	Ports []int
+	// DetectPorts set to true to detect the TCP ports which the program listens on,
+	// before it's stopped, and wait for them to be released as well.
+	// Supported only on linux.
+	DetectPorts bool
+	// PortsTimeout is the maximum time to wait for the ports to be released.
+	// Defaults to `DefaultPortsTimeout`.
+	PortsTimeout time.Duration
+	// BuildBeforeStop set to true to build the project before the running program is stopped,
+	// if the build fails then the running program keeps running and the compiler's error is reported,
+	// otherwise the running program is stopped and the new one starts.
+	// Defaults to false, the running program is stopped before the build.
+	BuildBeforeStop bool
+	// BuildTags are passed to the go build's -tags flag.
+	BuildTags []string
+	// LDFlags is passed to the go build's -ldflags flag, i.e "-s -w".
+	LDFlags string
+	// GCFlags is passed to the go build's -gcflags flag, i.e "all=-N -l".
+	GCFlags string
+	// Race set to true to build with the data race detector enabled, the go build's -race flag.
+	Race bool
+	// BuildArgs are extra arguments of the go build, they are passed before the package.
+	BuildArgs []string
+	// BuildEnv are extra environment variables of the build, in the form of "key=value",
+	// i.e "CGO_ENABLED=0" or "GOFLAGS=-mod=vendor".
+	BuildEnv []string
+	// Output is the path of the program's executable file.
+	// Defaults to a file inside a temporary directory, so the source tree is not polluted.
+	Output string
+	// BuildCommand is a custom command which replaces the go build, i.e []string{"make", "build"}.
+	// It runs inside the BuildDir and it should write the executable file
+	// to the path of its "RIZLA_OUTPUT" environment variable or, if BuildBeforeStop is false, to the Output.
+	BuildCommand []string
+	// BuildDir is the working directory of the BuildCommand, i.e the module's root for a "make build".
+	// Defaults to the main package's directory.
+	BuildDir string
+	// Stages are commands which run in order before each build, i.e go generate or go vet,
+	// with the environment variables of the build.
+	// A failed stage stops the reload, the running program keeps running and the next change fires a reload again.
+	// The first build runs all of them, the reloads run only the ones which the changed files trigger.
+	Stages []Stage
 
 	dir string
-	// proc the system Process of a running instance (if any)
-	proc *os.Process
-	// when the last change was made
+	// executable the path of the executable file of the program, the Output or a temporary file.
+	executable string
+	// nextExecutable the path of the new executable file, when BuildBeforeStop is true,
+	// it replaces the executable after the running program is stopped.
+	nextExecutable string
+	// buildDir the temporary directory of the builds when the Output is empty.
+	buildDir string
+	// imports the import graph of the main package, when WatchImportsOnly is true,
+	// it's reloaded by the reloader while the watcher reads it.
+	imports atomic.Pointer[importGraph]
+	// reloader reloads the project on changes, while it's running.
+	reloader *reloader
+	// moduleRoots the watched directories of the local modules, see `watchRoots`.
+	moduleRoots []string
+	// compiledPatterns the compiled Include and Exclude patterns, see `patterns`.
+	compiledPatterns atomic.Pointer[projectPatterns]
+	// gitignores the .gitignore files of the watched directories.
+	gitignores gitignores
+	// proc the running instance of the program (if any)
+	proc *process
+	// when the last reload was made
 	lastChange time.Time
-	// i%2 ==0 if windows, then the reload is allowed
-	i int
+	// runs the number of the times the program was started, see `Runs`.
+	runs int64
+}
+
+// PackageDir returns the absolute directory of a go package,
+// the "pkg" can be a directory, i.e "./cmd/api", or an import path, i.e "example.com/svc/cmd/worker",
+// import paths are resolved by the "go list" command, inside the working directory.
+func PackageDir(pkg string) (string, error) {
+	if isDirectory(pkg) {
+		return filepath.Abs(pkg)
+	}
+
+	out, err := exec.Command("go", "list", "-f", "{{.Dir}}", pkg).Output()
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok && len(exitErr.Stderr) > 0 {
+			return "", errors.New(strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return "", err
+	}
+
+	return strings.TrimSpace(string(out)), nil
 }
 
 // NewProject returns a simple project iteral which doesn't needs argument parameters
 // and has the default file matcher ( which is valid if you want to reload only on .Go files).
 //
+// The "target" is the main go file, i.e "main.go", or the main package, i.e "./cmd/api" or "example.com/svc/cmd/worker",
+// see `PackageDir`.
+//
 // You can change all of its fields before the .Run function.
-func NewProject(mainfile string, args ...string) *Project {
-	if mainfile == "" {
-		mainfile = "main.go"
+func NewProject(target string, args ...string) *Project {
+	if target == "" {
+		target = "main.go"
 	}
-	appName := mainfile[0 : len(mainfile)-len(goExt)]
-	mainfile, _ = filepath.Abs(mainfile)
 
-	dir := filepath.Dir(mainfile)
+	var mainfile, appName, dir string
+	if strings.HasSuffix(target, goExt) {
+		appName = target[0 : len(target)-len(goExt)]
+		mainfile, _ = filepath.Abs(target)
+		dir = filepath.Dir(mainfile)
+	} else {
+		var err error
+		if dir, err = PackageDir(target); err != nil {
+			// keep going, the build will report the error.
+			dir, _ = filepath.Abs(target)
+		}
+		appName = filepath.Base(dir)
+	}
 
 	p := &Project{
 		MainFile:                  mainfile,
@@ -154,15 +316,85 @@ func NewProject(mainfile string, args ...string) *Project {
 		Args:                      args,
 		Out:                       golog.New().SetOutput(os.Stdout),
 		Err:                       golog.New().SetOutput(os.Stderr),
-		Watcher:                   DefaultWatcher,
-		Matcher:                   DefaultGoMatcher,
 		AllowReloadAfter:          minimumAllowReloadAfter,
 		DisableProgramRerunOutput: DefaultDisableProgramRerunOutput,
+		StopSignal:                syscall.SIGTERM,
+		StopTimeout:               DefaultStopTimeout,
+		PortsTimeout:              DefaultPortsTimeout,
 		dir:                       dir,
 		lastChange:                time.Now(),
 	}
 
+	p.Watcher = p.watchDir
+	p.Matcher = p.matchFile
 	p.OnReload = DefaultOnReload(p)
 	p.OnReloaded = DefaultOnReloaded(p)
 	return p
 }
+
+// Dir returns the directory of the project's main package, which is built.
+func (p *Project) Dir() string {
+	return p.dir
+}
+
+// Runs returns the number of the times the project's program was started,
+// including the first run and each of the reloads.
+func (p *Project) Runs() int {
+	return int(atomic.LoadInt64(&p.runs))
+}
+
+// workDir returns the working directory of the program, the WorkDir or the package's directory.
+func (p *Project) workDir() string {
+	if p.WorkDir != "" {
+		return p.WorkDir
+	}
+	return p.dir
+}
+
+// watchRoots returns the directories which are watched, the Roots or the package's directory
+// and, unless DisableLocalModules is true, the directories of the local modules which the project depends on.
+func (p *Project) watchRoots() []string {
+	roots := p.roots()
+	p.moduleRoots = nil
+	if p.DisableLocalModules {
+		return roots
+	}
+
+	roots = append([]string(nil), roots...)
+	for _, dir := range localModuleDirs(p.dir) {
+		if !p.Watcher(dir) {
+			continue
+		}
+
+		watched := false
+		for _, root := range roots {
+			if isInside(root, dir) {
+				watched = true
+				break
+			}
+		}
+
+		if !watched {
+			roots = append(roots, dir)
+			p.moduleRoots = append(p.moduleRoots, dir)
+		}
+	}
+
+	return roots
+}
+
+// roots returns the Roots or the package's directory.
+func (p *Project) roots() []string {
+	if len(p.Roots) == 0 {
+		return []string{p.dir}
+	}
+	return p.Roots
+}
+
+// logPrefix returns the prefix of the project's log messages.
+func (p *Project) logPrefix() string {
+	if p.Name != "" {
+		return "From project '" + p.Name + "': "
+	}
+	return ""
+}
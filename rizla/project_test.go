@@ -1,7 +1,10 @@
 package rizla
 
 import (
+	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -15,10 +18,11 @@ func TestProjectMatcher(t *testing.T) {
 		"323232.go":                             true,
 		"-myfile2.go":                           true,
 		"_____.go":                              true,
-		".gooutput":                             !isWindows, // on non-windows the event is .gooutputblablabla, so we check for '.go'
-		".god":                                  !isWindows,
-		".goo":                                  !isWindows,
-		".go.dgo":                               !isWindows,
+		".gooutput":                             false, // the temporary files of some editors.
+		".god":                                  false,
+		".goo":                                  false,
+		".go.dgo":                               false,
+		"main.go~":                              false,
 		"":                                      false,
 	}
 	for k, v := range files {
@@ -45,3 +49,52 @@ func TestProjectPrepare(t *testing.T) {
 	}
 
 }
+
+func TestProjectPackage(t *testing.T) {
+	dir, _ := filepath.Abs(".")
+
+	p := NewProject(".")
+	if p.MainFile != "" {
+		t.Fatalf("expected an empty MainFile when created by a package but got %s", p.MainFile)
+	}
+
+	if p.Dir() != dir {
+		t.Fatalf("Dir is not the correct, expected %s but got %s", dir, p.Dir())
+	}
+
+	if p.workDir() != dir {
+		t.Fatalf("expected the working directory to default to %s but got %s", dir, p.workDir())
+	}
+
+	if roots := p.watchRoots(); len(roots) != 1 || roots[0] != dir {
+		t.Fatalf("expected the watched roots to default to [%s] but got %v", dir, roots)
+	}
+
+	p.WorkDir = os.TempDir()
+	p.Roots = []string{filepath.Dir(dir)}
+	if p.workDir() != p.WorkDir {
+		t.Fatalf("expected the working directory %s but got %s", p.WorkDir, p.workDir())
+	}
+
+	if roots := p.watchRoots(); len(roots) != 1 || roots[0] != p.Roots[0] {
+		t.Fatalf("expected the watched roots %v but got %v", p.Roots, roots)
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not available")
+	}
+
+	// import path.
+	pkgDir, err := PackageDir("net/http")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := filepath.Join("src", "net", "http"); !strings.HasSuffix(pkgDir, expected) {
+		t.Fatalf("expected the directory of net/http to end with %s but got %s", expected, pkgDir)
+	}
+
+	if _, err = PackageDir("./does/not/exist"); err == nil {
+		t.Fatal("expected an error for a package which does not exist")
+	}
+}
@@ -0,0 +1,243 @@
+package rizla
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+)
+
+// DefaultReadyTimeout is the default `ReadyCheck#Timeout`.
+var DefaultReadyTimeout = 30 * time.Second
+
+// DefaultReadyInterval is the default `ReadyCheck#Interval`.
+var DefaultReadyInterval = 250 * time.Millisecond
+
+// ReadyCheck describes when a started program is ready, when it's actually serving, see `Project#Ready`.
+// If more than one of the URL, Addr and Output are set then all of them should pass.
+type ReadyCheck struct {
+	// URL is an HTTP URL of the program, i.e "http://localhost:8080/health",
+	// the program is ready when a GET request to it responds with the Status.
+	URL string
+	// Status is the expected status code of the URL's response.
+	// Defaults to zero, any 2xx status code.
+	Status int
+	// Addr is a TCP address of the program, i.e "localhost:8080",
+	// the program is ready when it accepts connections on it.
+	Addr string
+	// Output is a regular expression, i.e regexp.MustCompile("listening on"),
+	// the program is ready when a line of its output, or of its error output, matches it.
+	Output *regexp.Regexp
+	// Timeout is the maximum time to wait for the program to be ready, the reload fails after that.
+	// Defaults to `DefaultReadyTimeout`.
+	Timeout time.Duration
+	// Interval is the time between two attempts of the URL or the Addr.
+	// Defaults to `DefaultReadyInterval`.
+	Interval time.Duration
+}
+
+var (
+	// errExitedBeforeReady is the error of a ready check when the program exits before it's ready.
+	errExitedBeforeReady = errors.New("the program exited before it was ready")
+	// errStoppedBeforeReady is the error of a ready check when rizla stops the program before it's ready,
+	// i.e for a reload.
+	errStoppedBeforeReady = errors.New("the program was stopped before it was ready")
+)
+
+// waitReady waits for the started "proc" of the project to be ready, see `Project#Ready`,
+// it returns immediately if the project has no ready check.
+func waitReady(ctx context.Context, p *Project, proc *process) error {
+	check := p.Ready
+	if check == nil || proc == nil {
+		return nil
+	}
+
+	timeout := check.Timeout
+	if timeout <= 0 {
+		timeout = DefaultReadyTimeout
+	}
+
+	started := time.Now()
+	readyCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	notReady := func(err error) error {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if err == nil {
+			return fmt.Errorf("the program is not ready after %s", timeout)
+		}
+		return fmt.Errorf("the program is not ready after %s: %v", timeout, err)
+	}
+
+	if check.Output != nil {
+		select {
+		case <-proc.outputMatched:
+		case <-proc.done:
+			return proc.notReadyErr()
+		case <-readyCtx.Done():
+			return notReady(fmt.Errorf("no output matches %q", check.Output))
+		}
+	}
+
+	for _, probe := range check.probes() {
+		for {
+			err := probe(readyCtx)
+			if err == nil {
+				break
+			}
+
+			select {
+			case <-proc.done:
+				return proc.notReadyErr()
+			case <-readyCtx.Done():
+				return notReady(err)
+			case <-time.After(check.interval()):
+			}
+		}
+	}
+
+	p.Out.Infof("%sThe program is ready in %s", p.logPrefix(), time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// notReadyErr returns the error of a ready check when the process has exited before it was ready.
+func (proc *process) notReadyErr() error {
+	if proc.stoppedByRizla() {
+		return errStoppedBeforeReady
+	}
+	return errExitedBeforeReady
+}
+
+func (check *ReadyCheck) interval() time.Duration {
+	if check.Interval <= 0 {
+		return DefaultReadyInterval
+	}
+	return check.Interval
+}
+
+// probes returns the attempts of the URL and the Addr, if any.
+func (check *ReadyCheck) probes() []func(ctx context.Context) error {
+	// an attempt waits for the interval, at least for a second.
+	attemptTimeout := check.interval()
+	if attemptTimeout < time.Second {
+		attemptTimeout = time.Second
+	}
+
+	var probes []func(ctx context.Context) error
+	if check.URL != "" {
+		probes = append(probes, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+			defer cancel()
+
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, check.URL, nil)
+			if err != nil {
+				return err
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+
+			if check.Status > 0 && resp.StatusCode != check.Status ||
+				check.Status <= 0 && (resp.StatusCode < 200 || resp.StatusCode > 299) {
+				return fmt.Errorf("unexpected status %s", resp.Status)
+			}
+			return nil
+		})
+	}
+
+	if check.Addr != "" {
+		probes = append(probes, func(ctx context.Context) error {
+			ctx, cancel := context.WithTimeout(ctx, attemptTimeout)
+			defer cancel()
+
+			var dialer net.Dialer
+			conn, err := dialer.DialContext(ctx, "tcp", check.Addr)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		})
+	}
+
+	return probes
+}
+
+// maxOutputLine is the maximum length of an output line which is kept by the outputMatcher,
+// longer lines are matched in parts.
+const maxOutputLine = 64 * 1024
+
+// outputMatcher is a writer of a program's output which calls its "matched"
+// when a line of the output matches the regular expression,
+// the output is written to the underline writer as it is.
+type outputMatcher struct {
+	w       io.Writer
+	re      *regexp.Regexp
+	matched func()
+	// line the incomplete last line of the output.
+	line []byte
+	done bool
+}
+
+// newOutputMatchers returns the writers of the standard output and the error output of a program,
+// the returned channel is closed when a line of any of them matches the "re".
+func newOutputMatchers(stdout, stderr io.Writer, re *regexp.Regexp) (io.Writer, io.Writer, chan struct{}) {
+	matchedCh := make(chan struct{})
+	var once sync.Once
+	matched := func() {
+		once.Do(func() { close(matchedCh) })
+	}
+
+	newMatcher := func(w io.Writer) *outputMatcher {
+		if w == nil {
+			w = io.Discard
+		}
+		return &outputMatcher{w: w, re: re, matched: matched}
+	}
+
+	return newMatcher(stdout), newMatcher(stderr), matchedCh
+}
+
+func (m *outputMatcher) Write(b []byte) (int, error) {
+	if !m.done {
+		m.line = append(m.line, b...)
+		for {
+			i := bytes.IndexByte(m.line, '\n')
+			if i < 0 {
+				break
+			}
+
+			if m.match(m.line[:i]) {
+				break
+			}
+			m.line = m.line[i+1:]
+		}
+
+		// the incomplete line too, i.e a prompt without a new line.
+		if !m.done && m.match(m.line) || len(m.line) > maxOutputLine {
+			m.line = nil
+		}
+	}
+
+	return m.w.Write(b)
+}
+
+func (m *outputMatcher) match(line []byte) bool {
+	if m.re.Match(line) {
+		m.done = true
+		m.line = nil
+		m.matched()
+	}
+	return m.done
+}
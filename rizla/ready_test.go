@@ -0,0 +1,92 @@
+package rizla
+
+import (
+	"bytes"
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestOutputMatcher(t *testing.T) {
+	var out bytes.Buffer
+	stdout, stderr, matched := newOutputMatchers(&out, nil, regexp.MustCompile(`listening on :\d+`))
+
+	stderr.Write([]byte(strings.Repeat("x", maxOutputLine+1)))
+	stdout.Write([]byte("starting\nlisten"))
+	select {
+	case <-matched:
+		t.Fatal("expected no match yet")
+	default:
+	}
+
+	// the incomplete line is matched as well.
+	stdout.Write([]byte("ing on :8080"))
+	stdout.Write([]byte("\nlistening on :8081\n"))
+	select {
+	case <-matched:
+	default:
+		t.Fatal("expected a match")
+	}
+
+	if expected, got := "starting\nlistening on :8080\nlistening on :8081\n", out.String(); expected != got {
+		t.Fatalf("expected the output to be written as it is: %q but got %q", expected, got)
+	}
+}
+
+func TestWaitReady(t *testing.T) {
+	var requests int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer srv.Close()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	p := newTestProject()
+	proc := &process{done: make(chan struct{}), outputMatched: make(chan struct{})}
+	close(proc.outputMatched)
+
+	p.Ready = &ReadyCheck{
+		URL:      srv.URL,
+		Addr:     ln.Addr().String(),
+		Output:   regexp.MustCompile("ready"),
+		Interval: 10 * time.Millisecond,
+		Timeout:  5 * time.Second,
+	}
+	if err = waitReady(context.Background(), p, proc); err != nil {
+		t.Fatal(err)
+	}
+	if n := atomic.LoadInt32(&requests); n != 3 {
+		t.Fatalf("expected the URL to be requested until it responds with a 2xx status but it was requested %d times", n)
+	}
+
+	p.Ready = &ReadyCheck{URL: srv.URL, Status: http.StatusCreated, Interval: 10 * time.Millisecond, Timeout: 200 * time.Millisecond}
+	if err = waitReady(context.Background(), p, proc); err == nil || !strings.Contains(err.Error(), "not ready") {
+		t.Fatalf("expected the unexpected status to time out but got %v", err)
+	}
+
+	// the program exits before it's ready.
+	ln.Close()
+	close(proc.done)
+	p.Ready = &ReadyCheck{Addr: ln.Addr().String(), Interval: 10 * time.Millisecond}
+	if err = waitReady(context.Background(), p, proc); err != errExitedBeforeReady {
+		t.Fatalf("expected the exit of the program to fail the check but got %v", err)
+	}
+
+	atomic.StoreInt32(&proc.stopping, 1)
+	if err = waitReady(context.Background(), p, proc); err != errStoppedBeforeReady {
+		t.Fatalf("expected the stop of the program to fail the check but got %v", err)
+	}
+}
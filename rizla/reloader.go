@@ -0,0 +1,380 @@
+package rizla
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// DefaultDebounce is the default `Project#Debounce`.
+var DefaultDebounce = 100 * time.Millisecond
+
+// reloader coalesces the changes of a project and reloads it, one reload at a time.
+//
+// The changes are collected until no change arrives for the project's quiet window (trailing-edge debounce),
+// then the project is reloaded once with all of them.
+// Changes that arrive while a reload is in progress are queued for the next one
+// and, if the project is still building, the stale build is canceled.
+// The files which the reload's own stages and hooks write, i.e a "go generate" stage, are not newer changes.
+type reloader struct {
+	p *Project
+
+	mu      sync.Mutex
+	pending []ChangeEvent
+	// index the index of a path in the pending changes.
+	index map[string]int
+	// notify is signaled on every change, it's buffered by one
+	// so the watcher never blocks and a change during a reload is never lost.
+	notify chan struct{}
+	// cancelBuild cancels the build of the reload in progress, if any.
+	cancelBuild context.CancelCauseFunc
+	// cycles the number of the finished reload cycles, including the skipped ones.
+	cycles int64
+	// exit stops the runner, it's called when a hook with the HookExit policy fails.
+	exit context.CancelCauseFunc
+	// hashes the content hashes of the watched files at the last build,
+	// nil when the project's DisableContentHash is true.
+	// It's guarded by the "mu", the watcher reads it on every change.
+	hashes fileHashes
+	// commands is true while the stages and the on reload hooks of a reload run,
+	// their changes are built by the reload, see `add`.
+	commands bool
+	// exitedProc the last program which its exit is handled, see `exited`.
+	exitedProc *process
+	// restarts the number of the consecutive restarts of the crashed program.
+	restarts int
+}
+
+func newReloader(p *Project) *reloader {
+	return &reloader{
+		p:      p,
+		index:  make(map[string]int),
+		notify: make(chan struct{}, 1),
+	}
+}
+
+// add queues a change, it never blocks.
+func (rl *reloader) add(evt ChangeEvent) {
+	unchanged := rl.unchanged(evt)
+
+	rl.mu.Lock()
+	if rl.commands {
+		// i.e a file which a stage generates, the build follows the stages so it contains the change.
+		rl.mu.Unlock()
+		return
+	}
+
+	rl.queue(evt)
+	if rl.cancelBuild != nil && !unchanged {
+		// a newer change, the build in progress is stale.
+		rl.cancelBuild(errBuildCanceled)
+	}
+	rl.mu.Unlock()
+
+	select {
+	case rl.notify <- struct{}{}:
+	default:
+	}
+}
+
+// unchanged reports whether the content of the changed file is the same as the last build,
+// i.e the late change of a file which a stage of the reload in progress has written, see `record`.
+func (rl *reloader) unchanged(evt ChangeEvent) bool {
+	if rl.hashes == nil || evt.Op&(Remove|Rename) != 0 {
+		return false
+	}
+
+	h, err := hashFile(evt.Path)
+	if err != nil {
+		return false
+	}
+
+	rl.mu.Lock()
+	last, ok := rl.hashes[evt.Path]
+	rl.mu.Unlock()
+	return ok && last == h
+}
+
+// setCommands marks the start and the end of the stages and the on reload hooks of a reload.
+func (rl *reloader) setCommands(running bool) {
+	rl.mu.Lock()
+	rl.commands = running
+	rl.mu.Unlock()
+}
+
+// record stores the content hashes of the watched files which have been changed since the last build,
+// i.e by the stages and the hooks, right before the build which contains them,
+// so their changes, which the watcher may report later, are not newer changes.
+// The hashes of the "changed" files are updated too, they are stored after the build.
+func (rl *reloader) record(changed fileHashes) {
+	if rl.hashes == nil {
+		return
+	}
+
+	hashes := hashFiles(rl.p)
+
+	rl.mu.Lock()
+	for filename, h := range hashes {
+		if last, ok := rl.hashes[filename]; ok && last == h {
+			continue
+		}
+
+		rl.hashes[filename] = h
+		if _, ok := changed[filename]; ok {
+			changed[filename] = h
+		}
+	}
+	rl.mu.Unlock()
+}
+
+// take returns and clears the queued changes,
+// the "cancelBuild" is called on the next change.
+func (rl *reloader) take(cancelBuild context.CancelCauseFunc) []ChangeEvent {
+	rl.mu.Lock()
+	changes := rl.pending
+	rl.pending = nil
+	rl.index = make(map[string]int)
+	rl.cancelBuild = cancelBuild
+	rl.mu.Unlock()
+	return changes
+}
+
+// requeue queues the "changes" of a canceled reload before the newer ones.
+func (rl *reloader) requeue(changes []ChangeEvent) {
+	rl.mu.Lock()
+	pending := rl.pending
+	rl.pending = nil
+	rl.index = make(map[string]int)
+	for _, evt := range append(append([]ChangeEvent(nil), changes...), pending...) {
+		rl.queue(evt)
+	}
+	rl.mu.Unlock()
+}
+
+// queue appends the "evt" to the pending changes,
+// a change of an already pending path is merged to the pending one.
+// It should be called under the lock.
+func (rl *reloader) queue(evt ChangeEvent) {
+	if i, ok := rl.index[evt.Path]; ok {
+		rl.pending[i].Op |= evt.Op
+		rl.pending[i].Time = evt.Time
+		return
+	}
+
+	rl.index[evt.Path] = len(rl.pending)
+	rl.pending = append(rl.pending, evt)
+}
+
+// quietWindow returns the time without changes to wait before a reload,
+// the Debounce or the AllowRunAfter if it's bigger.
+func (rl *reloader) quietWindow() time.Duration {
+	window := rl.p.Debounce
+	if window <= 0 {
+		window = DefaultDebounce
+	}
+
+	if rl.p.AllowRunAfter > window {
+		window = rl.p.AllowRunAfter
+	}
+	return window
+}
+
+// loop reloads the project on changes until the "ctx" is done.
+// It monitors the running program too, the program is only started and stopped here after the first run.
+func (rl *reloader) loop(ctx context.Context) {
+	for {
+		var exited <-chan struct{}
+		proc := rl.monitored()
+		if proc != nil {
+			exited = proc.done
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-exited:
+			rl.exited(ctx, proc)
+			continue
+		case <-rl.notify:
+		}
+
+		// wait until the changes are settled.
+		timer := time.NewTimer(rl.quietWindow())
+		for settled := false; !settled; {
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return
+			case <-rl.notify:
+				timer.Reset(rl.quietWindow())
+			case <-timer.C:
+				settled = true
+			}
+		}
+
+		// don't reload too fast from the last reload, the changes are kept meanwhile.
+		if wait := time.Until(rl.p.lastChange.Add(rl.p.AllowReloadAfter)); wait > 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+
+		buildCtx, cancelBuild := context.WithCancelCause(ctx)
+		if changes := rl.take(cancelBuild); len(changes) > 0 {
+			rl.reload(buildCtx, changes)
+		}
+
+		rl.mu.Lock()
+		rl.cancelBuild = nil
+		rl.mu.Unlock()
+		cancelBuild(nil)
+		atomic.AddInt64(&rl.cycles, 1)
+	}
+}
+
+// errBuildCanceled is the cause of a build's cancellation when a newer change arrives.
+var errBuildCanceled = errors.New("rizla: build canceled by a newer change")
+
+// reload reloads the project once for all of the "changes",
+// its build is aborted when the "ctx" is canceled.
+func (rl *reloader) reload(ctx context.Context, changes []ChangeEvent) {
+	p := rl.p
+
+	// editors that rewrite the files on save, touch and git checkout fire changes without a content change.
+	var (
+		effective []ChangeEvent
+		changed   fileHashes
+	)
+	if rl.hashes != nil {
+		rl.mu.Lock()
+		effective, changed = rl.hashes.effective(changes)
+		rl.mu.Unlock()
+		if len(effective) > 0 {
+			changes = effective
+		} else if p.proc != nil && !p.proc.exited() {
+			p.Out.Debugf("%sno effective change, the content of the changed files is the same as the last build", p.logPrefix())
+			return
+		}
+		// else the program has exited, i.e after a crash, a save without a content change starts it again.
+	}
+
+	// go.mod and go.work changes may change the imports, they fire a reload even if they are not matched.
+	importsChanged := false
+	if p.WatchImportsOnly {
+		imported := changes[:0:0]
+		for _, evt := range changes {
+			if isModFile(evt.Path) {
+				imported = append(imported, evt)
+				importsChanged = true
+				continue
+			}
+
+			if !p.imported(evt.Path) {
+				p.Out.Debugf("%s%s is not imported by the main package, skip reload", p.logPrefix(), evt.Path)
+				continue
+			}
+
+			imported = append(imported, evt)
+			importsChanged = importsChanged || filepath.Ext(evt.Path) == goExt
+		}
+
+		if changes = imported; len(changes) == 0 {
+			return
+		}
+	}
+
+	lastChange := p.lastChange
+	p.lastChange = time.Now()
+	p.OnReload(changes)
+
+	canceled := func() bool {
+		if context.Cause(ctx) != errBuildCanceled {
+			return false
+		}
+
+		p.Out.Infof("%sA newer change has been detected, the build is canceled", p.logPrefix())
+		// the next reload starts as soon as the changes are settled and it contains these changes too.
+		p.lastChange = lastChange
+		rl.requeue(changes)
+		return true
+	}
+
+	hooks := p.onReloadHooks()
+	rl.setCommands(true)
+	if err := runHooks(ctx, p, hooks, changes); err != nil {
+		rl.setCommands(false)
+		if !canceled() && ctx.Err() == nil {
+			rl.hookFailed(err, "the reload is stopped")
+		}
+		return
+	}
+
+	// the stages run before the running program is stopped, it keeps running if one of them fails.
+	err := runStages(ctx, p, changes)
+	rl.setCommands(false)
+	if err != nil {
+		if !canceled() && ctx.Err() == nil {
+			p.Err.Errorf("%s%v, the reload is stopped", p.logPrefix(), err)
+		}
+		return
+	}
+
+	if len(hooks) > 0 || len(p.Stages) > 0 {
+		rl.record(changed)
+	}
+
+	ok := reloadProject(ctx, p)
+	if !ok && canceled() {
+		return
+	}
+
+	// a failed build is the last build too, so a revert of the changes fires a reload.
+	if rl.hashes != nil {
+		rl.mu.Lock()
+		rl.hashes.update(effective, changed)
+		rl.mu.Unlock()
+	}
+
+	if !ok {
+		return
+	}
+	// a new program, its crashes are not consecutive to the previous ones.
+	rl.restarts = 0
+
+	if importsChanged {
+		p.loadImports()
+	}
+
+	if err := waitReady(ctx, p, p.proc); err != nil {
+		if ctx.Err() == nil {
+			p.Err.Errorf("%s%v", p.logPrefix(), err)
+		}
+		// else a newer change has been detected, its reload follows.
+		return
+	}
+
+	p.OnReloaded(changes)
+
+	// a newer change cancels them, its reload runs them again.
+	if err := runHooks(ctx, p, p.onReloadedHooks(), changes); err != nil && ctx.Err() == nil {
+		rl.hookFailed(err, "the program keeps running")
+	}
+}
+
+// hookFailed reports the error of a failed hook,
+// it stops the runner if the hook's policy is the HookExit.
+func (rl *reloader) hookFailed(err error, consequence string) {
+	p := rl.p
+	if herr, ok := err.(*hookError); ok && herr.hook.policy() == HookExit && rl.exit != nil {
+		p.Err.Errorf("%s%v, rizla is stopped", p.logPrefix(), err)
+		rl.exit(err)
+		return
+	}
+
+	p.Err.Errorf("%s%v, %s", p.logPrefix(), err, consequence)
+}
@@ -0,0 +1,115 @@
+package rizla
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// DefaultRestartBackoff is the default `Project#RestartBackoff`.
+var DefaultRestartBackoff = 500 * time.Millisecond
+
+// DefaultMaxRestartBackoff is the maximum delay of a restart, the backoff doubles on each consecutive crash up to it.
+var DefaultMaxRestartBackoff = 30 * time.Second
+
+// DefaultMaxRestarts is the default `Project#MaxRestarts`.
+var DefaultMaxRestarts = 5
+
+// restartResetAfter is the running time of the program after which a crash is not a consecutive one,
+// the restarts and their backoff start over.
+const restartResetAfter = time.Minute
+
+func (p *Project) maxRestarts() int {
+	if p.MaxRestarts == 0 {
+		return DefaultMaxRestarts
+	}
+	return p.MaxRestarts
+}
+
+// restartBackoff returns the delay of the restart after the "restarts" consecutive ones.
+func (p *Project) restartBackoff(restarts int) time.Duration {
+	backoff := p.RestartBackoff
+	if backoff <= 0 {
+		backoff = DefaultRestartBackoff
+	}
+
+	for i := 0; i < restarts && backoff < DefaultMaxRestartBackoff; i++ {
+		backoff *= 2
+	}
+
+	if backoff > DefaultMaxRestartBackoff {
+		backoff = DefaultMaxRestartBackoff
+	}
+	return backoff
+}
+
+// monitored returns the running program of the project if its exit is not handled yet,
+// the programs which rizla stops, i.e for a reload, are not monitored.
+func (rl *reloader) monitored() *process {
+	proc := rl.p.proc
+	if proc == nil || proc == rl.exitedProc || proc.stoppedByRizla() {
+		return nil
+	}
+	return proc
+}
+
+// exited handles the exit of a program which rizla didn't stop, it reports its exit status
+// and, if the project's RestartOnCrash is true and the program has crashed, it starts the program again after a backoff.
+func (rl *reloader) exited(ctx context.Context, proc *process) {
+	p := rl.p
+	rl.exitedProc = proc
+	if proc.stoppedByRizla() {
+		return
+	}
+
+	if !proc.crashed() {
+		p.Out.Infof("%sThe program has exited (%s), it starts again on the next change", p.logPrefix(), proc.exitStatus())
+		return
+	}
+
+	if !p.RestartOnCrash {
+		p.Err.Errorf("%sThe program has crashed (%s), it starts again on the next change", p.logPrefix(), proc.exitStatus())
+		return
+	}
+
+	if time.Since(proc.started) >= restartResetAfter {
+		rl.restarts = 0
+	}
+
+	max := p.maxRestarts()
+	if max >= 0 && rl.restarts >= max {
+		p.Err.Errorf("%sThe program has crashed (%s) %d times in a row, it starts again on the next change",
+			p.logPrefix(), proc.exitStatus(), rl.restarts+1)
+		return
+	}
+
+	backoff := p.restartBackoff(rl.restarts)
+	rl.restarts++
+
+	limit := ""
+	if max >= 0 {
+		limit = fmt.Sprintf("/%d", max)
+	}
+	p.Err.Errorf("%sThe program has crashed (%s), restarting it in %s (%d%s)...",
+		p.logPrefix(), proc.exitStatus(), backoff, rl.restarts, limit)
+
+	timer := time.NewTimer(backoff)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return
+	case <-rl.notify:
+		// a change, its reload starts the program.
+		select {
+		case rl.notify <- struct{}{}:
+		default:
+		}
+		return
+	case <-timer.C:
+	}
+
+	if err := runProject(p); err != nil {
+		p.Err.Errorf("%sfailed to restart the program: %v", p.logPrefix(), err)
+	}
+}
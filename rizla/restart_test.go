@@ -0,0 +1,89 @@
+package rizla
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestRestartBackoff(t *testing.T) {
+	p := &Project{RestartBackoff: time.Second}
+
+	tests := map[int]time.Duration{
+		0:  time.Second,
+		1:  2 * time.Second,
+		3:  8 * time.Second,
+		5:  DefaultMaxRestartBackoff,
+		60: DefaultMaxRestartBackoff,
+	}
+
+	for restarts, expected := range tests {
+		if got := p.restartBackoff(restarts); got != expected {
+			t.Fatalf("expected backoff %s after %d restarts but got %s", expected, restarts, got)
+		}
+	}
+}
+
+const crasherProgram = `package main
+
+import "os"
+
+func main() {
+	os.Exit(3)
+}
+`
+
+// waitRuns waits for the program of the project to be started "runs" times,
+// and a bit more to make sure that it's not started again.
+func waitRuns(t *testing.T, p *Project, runs int) {
+	deadline := time.Now().Add(30 * time.Second)
+	for p.Runs() < runs && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	time.Sleep(300 * time.Millisecond)
+	if got := p.Runs(); got != runs {
+		t.Fatalf("expected the program to run %d times but it ran %d times", runs, got)
+	}
+}
+
+func TestRestartOnCrash(t *testing.T) {
+	p := newTestProgram(t, crasherProgram)
+	p.DisableContentHash = true
+	p.RestartOnCrash = true
+	p.RestartBackoff = 10 * time.Millisecond
+	p.MaxRestarts = 2
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+	}()
+
+	// the first run and the two restarts.
+	waitRuns(t, p, 3)
+
+	// a reload starts over the restarts.
+	w.change(p, p.MainFile)
+	waitRuns(t, p, 6)
+}
+
+func TestCrashWithoutRestart(t *testing.T) {
+	p := newTestProgram(t, crasherProgram)
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+	}()
+
+	waitRuns(t, p, 1)
+
+	// a save without a content change starts the exited program again.
+	w.change(p, p.MainFile)
+	waitRuns(t, p, 2)
+}
@@ -2,12 +2,13 @@
 package rizla
 
 import (
+	"context"
 	"errors"
 	"os"
 	"os/exec"
-	"path/filepath"
 	"runtime"
-	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/kataras/golog"
@@ -25,33 +26,87 @@ var (
 	// Change its printer's Output (io.Writer) by `Out.SetOutput(io.Writer)`.
 	Out = golog.New().SetOutput(os.Stdout)
 
+	pathSeparator = string(os.PathSeparator)
+
+	// defaultRunner is the Runner which the package-level functions are using.
+	defaultRunner = &Runner{Out: Out}
+)
+
+// Runner builds, runs and monitors a set of projects.
+// Each Runner owns its projects, its file system watcher and its logger,
+// so more than one Runner can live side by side in the same process.
+//
+// The package-level `Add`, `Run`, `RunWith` and `Stop` functions are using a default Runner.
+type Runner struct {
+	// Out is the logger which prints watcher errors and information.
+	Out *golog.Logger
+
+	mu       sync.Mutex
 	projects []*Project
+	watcher  Watcher
+	// cancel stops the running RunContext, if any.
+	cancel context.CancelCauseFunc
+	// stopped is set by a Stop call while the runner is not running,
+	// the next RunContext returns immediately.
+	stopped bool
+}
 
-	pathSeparator = string(os.PathSeparator)
+// New returns a new, empty, Runner which logs to the os.Stdout.
+func New() *Runner {
+	return &Runner{
+		Out: golog.New().SetOutput(os.Stdout),
+	}
+}
+
+// Add project(s) to the runner.
+func (r *Runner) Add(proj ...*Project) {
+	r.mu.Lock()
+	r.projects = append(r.projects, proj...)
+	r.mu.Unlock()
+}
 
-	stopChan = make(chan bool, 1)
+// RemoveAll clears the runner's projects, doesn't stop them if running.
+func (r *Runner) RemoveAll() {
+	r.mu.Lock()
+	r.projects = make([]*Project, 0)
+	r.mu.Unlock()
+}
 
-	fsWatcher Watcher
-)
+// Len how much projects have been added to the runner so far.
+func (r *Runner) Len() int {
+	r.mu.Lock()
+	n := len(r.projects)
+	r.mu.Unlock()
+	return n
+}
+
+// Projects returns a copy of the runner's projects.
+func (r *Runner) Projects() []*Project {
+	r.mu.Lock()
+	projects := make([]*Project, len(r.projects))
+	copy(projects, r.projects)
+	r.mu.Unlock()
+	return projects
+}
 
-// Add project(s) to the container
+// Add project(s) to the default runner.
 func Add(proj ...*Project) {
-	for _, p := range proj {
-		projects = append(projects, p)
-	}
+	defaultRunner.Add(proj...)
 }
 
-// RemoveAll clears the current projects, doesn't stop them if running
+// RemoveAll clears the default runner's projects, doesn't stop them if running.
 func RemoveAll() {
-	projects = make([]*Project, 0)
+	defaultRunner.RemoveAll()
 }
 
-// Len how much projects have  been added so far
+// Len how much projects have been added to the default runner so far.
 func Len() int {
-	return len(projects)
+	return defaultRunner.Len()
 }
 
-var errUnexpected = errors.New("unexpected error!!! Please post an issue here: https://github.com/kataras/rizla/issues")
+// ErrStopped is the error which `Runner#RunContext` returns
+// when it was terminated by the `Runner#Stop` method.
+var ErrStopped = errors.New("rizla: stopped")
 
 // RunWith starts the repeat of the build-run-watch-reload task of all projects
 // receives optional parameters which can be the main source file
@@ -61,121 +116,188 @@ var errUnexpected = errors.New("unexpected error!!! Please post an issue here: h
 // second (optional) parameter(s) are the directories of the projects.
 //    it's optional because they can be added with the .Add(NewProject) before the RunWith.
 //
-func RunWith(watcher Watcher, sources map[string][]string, delayOnDetect time.Duration) {
-	// Author's notes: Because rizla's Run is not allowed to be called more than once
-	// the whole package works as it is, so the watcher here
-	// is CHANGING THE UNEXPORTED PACKGE VARIABLE 'fsWatcher'.
-	// We don't export the 'fsWatcher' directly because this may cause issues
-	// if user tries to change it while it runs.
-	fsWatcher = watcher
+// It blocks until the runner is stopped, see `RunContext` for a cancelable version.
+func (r *Runner) RunWith(watcher Watcher, sources map[string][]string, delayOnDetect time.Duration) {
+	if err := r.RunContext(context.Background(), watcher, sources, delayOnDetect); err != nil && err != ErrStopped {
+		r.Out.Error(err)
+	}
+}
+
+// RunContext same as `RunWith` but it stops watching when the "ctx" is canceled
+// or when the `Stop` method is called.
+// Before return, it kills every process that it started.
+//
+// The returned error describes why the runner stopped,
+// it's the `ErrStopped` when stopped by the `Stop` method,
+// the context's cause when the "ctx" is done
+// or the watcher's error if the watcher failed.
+func (r *Runner) RunContext(ctx context.Context, watcher Watcher, sources map[string][]string, delayOnDetect time.Duration) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	// Author's notes: we don't allow the watcher to be changed
+	// while the runner is running, so it's set-ed here once
+	// and it's used by the Stop and Run methods later on.
+	r.mu.Lock()
+	if r.stopped {
+		// i.e Stop was called before the RunContext of a goroutine has started.
+		r.stopped = false
+		r.mu.Unlock()
+		return ErrStopped
+	}
+	r.watcher = watcher
+	r.cancel = cancel
+	r.mu.Unlock()
+
+	defer func() {
+		r.mu.Lock()
+		r.cancel = nil
+		r.mu.Unlock()
+	}()
 
 	if len(sources) > 0 {
 		for programFile, args := range sources {
 			project := NewProject(programFile, args...)
 			project.AllowRunAfter = delayOnDetect
-			Add(project)
+			r.Add(project)
 		}
 	}
 
+	projects := r.Projects()
+	wg := new(sync.WaitGroup)
+
 	for _, p := range projects {
+		if err := runStages(ctx, p, nil); err != nil {
+			p.Err.Error(err)
+			continue
+		}
+
 		// go build
-		if err := buildProject(p); err != nil {
-			p.Err.Errorf(err.Error())
+		if err := buildProject(ctx, p); err != nil {
+			p.Err.Error(err)
 			continue
 		}
 
 		// exec run the builded program
 		if err := runProject(p); err != nil {
-			p.Err.Errorf(err.Error())
+			p.Err.Error(err)
 			continue
 		}
 
+		// the readiness is reported in the background, the rest of the projects start meanwhile.
+		wg.Add(1)
+		go func(p *Project, proc *process) {
+			if err := waitReady(ctx, p, proc); err != nil && err != errStoppedBeforeReady && ctx.Err() == nil {
+				p.Err.Errorf("%s%v", p.logPrefix(), err)
+			}
+			wg.Done()
+		}(p, p.proc)
 	}
 
 	watcher.OnError(func(err error) {
-		Out.Errorf(err.Error())
+		r.Out.Error(err)
 	})
 
-	watcher.OnChange(func(p *Project, filename string) {
-		if time.Now().After(p.lastChange.Add(p.AllowReloadAfter)) {
-			if match := p.Matcher(filename); !match {
-				return
-			}
-
-			if p.AllowRunAfter > 0 {
-
-				// Note that here, at "AllowRunAfter", maybe a lot of re-builds
-				// at the same time if the user saved without
-				// "AllowReloadAfter" configured, so every of the changes
-				// are allowed in short period of time.
-				// As a solution if AllowReloadAfter is not configured we will
-				// configure it here, we can't do it before the first try
-				// because it will wrong to wait "x" time to the first change detect allow.
-				// We could make it with 1-buf channel as well.
-				if p.AllowReloadAfter == 0 {
-					p.lastChange = time.Now()
-					p.AllowReloadAfter = p.AllowRunAfter
-					// if minus := 250 * time.Millisecond; p.AllowRunAfter > minus {
-					// 	p.AllowReloadAfter = p.AllowRunAfter - minus
-					// }
-				}
-				time.Sleep(p.AllowRunAfter)
-			}
+	for _, p := range projects {
+		// the Include and Exclude may be changed since the project was created.
+		p.loadPatterns()
+		if p.WatchImportsOnly {
+			p.loadImports()
+		}
 
-			p.lastChange = time.Now()
-			p.OnReload(filename)
+		p.reloader = newReloader(p)
+		p.reloader.exit = cancel
+		if !p.DisableContentHash {
+			p.reloader.hashes = hashFiles(p)
+		}
 
-			// kill previous running instance
-			err := killProcess(p.proc, p.AppName)
-			if err != nil {
-				p.Err.Errorf("kill: %v", err)
-				return
-			}
+		wg.Add(1)
+		go func(rl *reloader) {
+			rl.loop(ctx)
+			wg.Done()
+		}(p.reloader)
+	}
 
-			// go build
-			err = buildProject(p)
-			if err != nil {
-				p.Err.Errorf(err.Error())
-				return
-			}
+	watcher.OnChange(func(evt ChangeEvent) {
+		p := evt.Project
+		if !p.triggers(evt) || !p.watches(evt.Path) {
+			return
+		}
 
-			// exec run the builded program
-			err = runProject(p)
-			if err != nil {
-				p.Err.Errorf("failed to run the project: %v", err)
-				return
-			}
+		// the reload happens at the project's reloader, when the changes are settled.
+		p.reloader.add(evt)
+	})
 
-			p.OnReloaded(filename)
+	err := watcher.Loop(ctx, projects)
+	// stop the reloaders too, if the watcher failed.
+	cancel(err)
+	wg.Wait()
 
+	for _, p := range projects {
+		if kerr := killProcess(p); kerr != nil {
+			p.Err.Errorf("kill: %v", kerr)
 		}
-	})
+		removeBuildDir(p)
+	}
 
-	watcher.Loop()
+	return err
 }
 
 // Run same as RunWith but runs with the default file system watcher
 // which is the fsnotify (watch over file system's signals) or the last used with RunWith.
 //
 // It's a map of main files and their arguments, if any.
-func Run(sources map[string][]string) {
-	if fsWatcher != nil {
-		// if user already called RunWith before, the watcher is saved on the 'fsWatcher' variable,
+func (r *Runner) Run(sources map[string][]string) {
+	r.mu.Lock()
+	watcher := r.watcher
+	r.mu.Unlock()
+
+	if watcher != nil {
+		// if user already called RunWith before, the watcher is saved on the runner,
 		// use that instead.
-		RunWith(fsWatcher, sources, 0)
+		r.RunWith(watcher, sources, 0)
 		return
 	}
 
-	RunWith(newSignalWatcher(), sources, 0)
+	r.RunWith(newSignalWatcher(), sources, 0)
 }
 
 // Stop any projects are watched by the RunWith/Run method, this function should be call when you call the Run inside a goroutine.
-func Stop() {
-	if fsWatcher != nil {
-		fsWatcher.Stop()
+// If the runner is not running yet, i.e its goroutine has not started, then the next run returns immediately.
+// It's safe to call it more than once.
+func (r *Runner) Stop() {
+	r.mu.Lock()
+	cancel := r.cancel
+	if cancel == nil {
+		r.stopped = true
+	}
+	r.mu.Unlock()
+
+	if cancel != nil {
+		cancel(ErrStopped)
 	}
 }
 
+// RunContext same as `Runner#RunContext` but for the default runner.
+func RunContext(ctx context.Context, watcher Watcher, sources map[string][]string, delayOnDetect time.Duration) error {
+	return defaultRunner.RunContext(ctx, watcher, sources, delayOnDetect)
+}
+
+// RunWith same as `Runner#RunWith` but for the default runner.
+func RunWith(watcher Watcher, sources map[string][]string, delayOnDetect time.Duration) {
+	defaultRunner.RunWith(watcher, sources, delayOnDetect)
+}
+
+// Run same as `Runner#Run` but for the default runner.
+func Run(sources map[string][]string) {
+	defaultRunner.Run(sources)
+}
+
+// Stop same as `Runner#Stop` but for the default runner.
+func Stop() {
+	defaultRunner.Stop()
+}
+
 func isDirectory(fullname string) bool {
 	if info, err := os.Stat(fullname); err == nil && info.IsDir() {
 		return true
@@ -183,32 +305,61 @@ func isDirectory(fullname string) bool {
 	return false
 }
 
-func buildProject(p *Project) error {
+// reloadProject stops, builds and runs the project again, the stages run before it, see `reloader#reload`.
+// If the project's BuildBeforeStop is true then the project is built first
+// and the running program is stopped only if the build succeed.
+// The build is aborted when the "ctx" is canceled.
+//
+// It reports whether the new program is running.
+func reloadProject(ctx context.Context, p *Project) bool {
+	if p.BuildBeforeStop {
+		// go build, the previous program keeps running on failure.
+		if err := buildProject(ctx, p); err != nil {
+			if ctx.Err() == nil {
+				p.Err.Errorf("%sbuild failed, the previous program keeps running: %v", p.logPrefix(), err)
+			}
+			return false
+		}
+	}
 
-	// relative := p.MainFile[len(p.dir)+1:len(p.MainFile)-3] + goExt
-	goBuild := exec.Command("go", "build", ".")
-	goBuild.Dir = p.dir
-	goBuild.Stdout = p.Out.Printer.Output
-	goBuild.Stderr = p.Err.Printer.Output
-	return goBuild.Run()
-}
+	// kill previous running instance
+	if err := stopProject(p); err != nil {
+		p.Err.Errorf("kill: %v", err)
+		return false
+	}
 
-func runProject(p *Project) error {
+	if !p.BuildBeforeStop {
+		// go build
+		if err := buildProject(ctx, p); err != nil {
+			if ctx.Err() == nil {
+				p.Err.Error(err)
+			}
+			return false
+		}
+	}
 
-	// buildProject := p.MainFile[len(p.dir) : len(p.MainFile)-3] // with prepended slash
+	// exec run the builded program
+	if err := runProject(p); err != nil {
+		p.Err.Errorf("failed to run the project: %v", err)
+		return false
+	}
 
-	buildProject := filepath.Base(p.dir)
+	return true
+}
 
-	if isWindows {
-		buildProject += ".exe"
+func runProject(p *Project) error {
+	if p.nextExecutable != "" {
+		// the previous program is stopped, replace its executable with the new one.
+		if err := os.Rename(p.nextExecutable, p.executable); err != nil {
+			return err
+		}
+		p.nextExecutable = ""
 	}
 
-	// runCmd := exec.Command("."+buildProject, p.Args...)
+	runCmd := exec.Command(p.executable, p.Args...)
+	runCmd.Dir = p.workDir()
 
-	runCmd := exec.Command("./"+buildProject, p.Args...)
-	runCmd.Dir = p.dir
-
-	if p.DisableProgramRerunOutput && p.i > 0 && p.proc != nil {
+	if p.DisableProgramRerunOutput && p.Runs() > 0 {
 		// if already ran once succesfuly, we don't need to printout the output of the program, because we will have big outputs if the program has banner (like Iris :))
 	} else {
 		runCmd.Stdout = p.Out.Printer.Output
@@ -216,53 +367,22 @@ func runProject(p *Project) error {
 
 	runCmd.Stderr = p.Err.Printer.Output
 
+	var outputMatched chan struct{}
+	if p.Ready != nil && p.Ready.Output != nil {
+		runCmd.Stdout, runCmd.Stderr, outputMatched = newOutputMatchers(runCmd.Stdout, runCmd.Stderr, p.Ready.Output)
+	}
+
 	// Moved to exec.Command's second argument instead:
 	// if p.Args != nil && len(p.Args) > 0 {
 	// 	runCmd.Args = p.Args[0 : len(p.Args)-1]
 	// }
 
-	if err := runCmd.Start(); err != nil {
+	proc, err := startProcess(runCmd)
+	if err != nil {
 		return err
 	}
-	p.proc = runCmd.Process
+	proc.outputMatched = outputMatched
+	p.proc = proc
+	atomic.AddInt64(&p.runs, 1)
 	return nil
 }
-
-func killProcess(proc *os.Process, appName string) (err error) {
-	if proc == nil {
-		return nil
-	}
-
-	if !isMac {
-		err = proc.Release()
-		if err != nil {
-			return nil // to prevent throw an error if the proc is not yet started correctly (= previous build error)
-		}
-	}
-
-	if (isWindows || isMac) && proc.Pid <= 0 {
-		return nil
-	}
-
-	err = proc.Kill()
-	if err == nil {
-		_, err = proc.Wait()
-	} else {
-		// force kill, sometimes proc.Kill or Signal(os.Kill) doesn't kills
-		if isWindows {
-			err = exec.Command("taskkill", "/F", "/T", "/PID", strconv.Itoa(proc.Pid)).Run()
-			if err != nil && err.Error() == "exit status 128" {
-				err = nil // skip that stupid error here.
-			}
-
-			// err = exec.Command("taskkill", "/im", appName+".exe").Run()
-		} else if isMac {
-			err = exec.Command("killall", "-KILL", strconv.Itoa(proc.Pid)).Run()
-		} else {
-			// err = exec.Command("kill", "-INT", "-"+strconv.Itoa(proc.Pid)).Run()
-			err = exec.Command("pkill", "-SIGINT", appName).Run()
-		}
-	}
-	proc = nil
-	return
-}
@@ -0,0 +1,496 @@
+package rizla
+
+import (
+	"context"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRunnerProjects(t *testing.T) {
+	r1, r2 := New(), New()
+
+	r1.Add(NewProject("project_test.go"), NewProject("rizla_test.go"))
+	r2.Add(NewProject("project_test.go"))
+
+	if expected, got := 2, r1.Len(); expected != got {
+		t.Fatalf("first runner, expected %d projects but got %d", expected, got)
+	}
+
+	if expected, got := 1, r2.Len(); expected != got {
+		t.Fatalf("second runner, expected %d projects but got %d", expected, got)
+	}
+
+	if got := Len(); got != 0 {
+		t.Fatalf("default runner, expected to be empty but got %d projects", got)
+	}
+
+	r1.RemoveAll()
+	if got := r1.Len(); got != 0 {
+		t.Fatalf("first runner, expected to be empty after RemoveAll but got %d projects", got)
+	}
+
+	if expected, got := 1, r2.Len(); expected != got {
+		t.Fatalf("second runner, expected %d projects after first's RemoveAll but got %d", expected, got)
+	}
+}
+
+// loopWatcher is a Watcher which notifies when its Loop has been started
+// and fires changes only when its change method is called.
+type loopWatcher struct {
+	started         chan struct{}
+	changeListeners []WatcherChangeListener
+}
+
+func (w *loopWatcher) OnChange(evt WatcherChangeListener) {
+	w.changeListeners = append(w.changeListeners, evt)
+}
+
+func (w *loopWatcher) OnError(WatcherErrorListener) {}
+
+// fire fires a change without waiting for the reload.
+func (w *loopWatcher) fire(p *Project, filename string) {
+	for i := range w.changeListeners {
+		w.changeListeners[i](NewChangeEvent(p, filename, Write))
+	}
+}
+
+// change fires a change and waits for the project's reloader to handle it.
+func (w *loopWatcher) change(p *Project, filename string) {
+	cycles := atomic.LoadInt64(&p.reloader.cycles)
+	w.fire(p, filename)
+	waitReloadCycle(p, cycles)
+}
+
+// changedPaths returns the paths of the "changes".
+func changedPaths(changes []ChangeEvent) []string {
+	paths := make([]string, len(changes))
+	for i, evt := range changes {
+		paths[i] = evt.Path
+	}
+	return paths
+}
+
+// waitReloadCycle waits for the project's reloader to finish a reload cycle after the "cycles" ones.
+func waitReloadCycle(p *Project, cycles int64) {
+	deadline := time.Now().Add(30 * time.Second)
+	for atomic.LoadInt64(&p.reloader.cycles) <= cycles && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func (w *loopWatcher) Loop(ctx context.Context, _ []*Project) error {
+	close(w.started)
+	<-ctx.Done()
+	return context.Cause(ctx)
+}
+
+func runInBackground(r *Runner, ctx context.Context) (*loopWatcher, chan error) {
+	w := &loopWatcher{started: make(chan struct{})}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- r.RunContext(ctx, w, nil, 0)
+	}()
+	<-w.started
+	return w, errCh
+}
+
+func waitRunError(t *testing.T, errCh chan error) error {
+	select {
+	case err := <-errCh:
+		return err
+	case <-time.After(5 * time.Second):
+		t.Fatal("runner did not stop in time")
+		return nil
+	}
+}
+
+func TestRunnerRunContextCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	_, errCh := runInBackground(New(), ctx)
+
+	cancel()
+	if err := waitRunError(t, errCh); err != context.Canceled {
+		t.Fatalf("expected error %v but got %v", context.Canceled, err)
+	}
+}
+
+func TestRunnerStop(t *testing.T) {
+	r := New()
+	_, errCh := runInBackground(r, context.Background())
+
+	// calling Stop more than once should not block.
+	r.Stop()
+	r.Stop()
+	if err := waitRunError(t, errCh); err != ErrStopped {
+		t.Fatalf("expected error %v but got %v", ErrStopped, err)
+	}
+
+	// and after the runner stopped as well.
+	r.Stop()
+}
+
+func TestRunnerStopBeforeRun(t *testing.T) {
+	r := New()
+	r.Stop()
+
+	w := &loopWatcher{started: make(chan struct{})}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- r.RunContext(context.Background(), w, nil, 0)
+	}()
+
+	if err := waitRunError(t, errCh); err != ErrStopped {
+		t.Fatalf("expected error %v but got %v", ErrStopped, err)
+	}
+
+	// the stop request is consumed by the stopped run.
+	_, errCh = runInBackground(r, context.Background())
+	r.Stop()
+	if err := waitRunError(t, errCh); err != ErrStopped {
+		t.Fatalf("expected error %v but got %v", ErrStopped, err)
+	}
+}
+
+// newTestProgram writes the "src" as the main.go file of a new go module
+// and returns a project of it, which reloads on every change.
+func newTestProgram(t *testing.T, src string) *Project {
+	if testing.Short() {
+		t.Skip("skipping the build of a program in short mode")
+	}
+
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go is not available")
+	}
+
+	dir := t.TempDir()
+	mainFile := filepath.Join(dir, "main.go")
+	if err := os.WriteFile(mainFile, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module program\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProject()
+	p.MainFile = mainFile
+	p.dir = dir
+	p.AllowReloadAfter = 0
+	return p
+}
+
+const sleeperProgram = `package main
+
+import "time"
+
+func main() {
+	time.Sleep(time.Hour)
+}
+`
+
+func TestReloadBuildBeforeStop(t *testing.T) {
+	p := newTestProgram(t, sleeperProgram)
+	p.BuildBeforeStop = true
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+		if p.buildDir != "" {
+			t.Fatalf("expected the build directory to be removed")
+		}
+	}()
+
+	first := p.proc
+	if first == nil || !first.alive() {
+		t.Fatal("expected the program to be running")
+	}
+
+	// compile error, the program should keep running.
+	if err := os.WriteFile(p.MainFile, []byte("package main\n\nfunc main() { undefined() }\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.change(p, p.MainFile)
+
+	if p.proc != first || !first.alive() {
+		t.Fatal("expected the previous program to keep running after a failed build")
+	}
+
+	if err := os.WriteFile(p.MainFile, []byte(sleeperProgram), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.change(p, p.MainFile)
+
+	if p.proc == first || first.alive() {
+		t.Fatal("expected the previous program to be replaced after a successful build")
+	}
+
+	if !p.proc.alive() {
+		t.Fatal("expected the new program to be running")
+	}
+
+	if filepath.Dir(p.executable) != p.buildDir {
+		t.Fatalf("expected the executable to be built inside %s but got %s", p.buildDir, p.executable)
+	}
+
+	if entries, _ := os.ReadDir(p.buildDir); len(entries) != 1 {
+		t.Fatalf("expected only the running executable inside the build directory but got %d files", len(entries))
+	}
+}
+
+func TestReloadDebounce(t *testing.T) {
+	p := newTestProgram(t, sleeperProgram)
+	otherFile := filepath.Join(p.dir, "other.go")
+	lateFile := filepath.Join(p.dir, "late.go")
+	// the changes are fired without a content change.
+	p.DisableContentHash = true
+
+	var w *loopWatcher
+	reloads := make(chan []string, 3)
+	p.OnReload = func(changes []ChangeEvent) {
+		reloads <- changedPaths(changes)
+	}
+	p.OnReloaded = func([]ChangeEvent) {
+		if len(reloads) == 1 {
+			// a change before the reload cycle ends, it should fire one more reload.
+			w.fire(p, lateFile)
+		}
+	}
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+	}()
+
+	cycles := atomic.LoadInt64(&p.reloader.cycles)
+	w.fire(p, p.MainFile)
+	w.fire(p, otherFile)
+	w.fire(p, p.MainFile)
+	waitReloadCycle(p, cycles)
+	waitReloadCycle(p, cycles+1)
+
+	if expected, got := []string{p.MainFile, otherFile}, <-reloads; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected one reload with the changes %v but got %v", expected, got)
+	}
+
+	if expected, got := []string{lateFile}, <-reloads; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected one more reload with the changes %v but got %v", expected, got)
+	}
+
+	if n := len(reloads); n != 0 {
+		t.Fatalf("expected two reloads but got %d more", n)
+	}
+}
+
+func TestReloadCancelBuild(t *testing.T) {
+	if isWindows {
+		t.Skip("the build command needs a shell")
+	}
+
+	p := newTestProgram(t, sleeperProgram)
+	otherFile := filepath.Join(p.dir, "other.go")
+	slowFile := filepath.Join(p.dir, "slow")
+	// the build hangs while the "slow" file exists.
+	p.BuildCommand = []string{"sh", "-c", `if [ -f slow ]; then sleep 30; fi; go build -o "$RIZLA_OUTPUT" .`}
+	p.DisableContentHash = true
+
+	building := make(chan struct{}, 2)
+	p.OnReload = func([]ChangeEvent) {
+		building <- struct{}{}
+	}
+	reloaded := make(chan []string, 1)
+	p.OnReloaded = func(changes []ChangeEvent) {
+		reloaded <- changedPaths(changes)
+	}
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+	}()
+
+	if err := os.WriteFile(slowFile, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.fire(p, p.MainFile)
+	<-building
+
+	// a newer change should cancel the hanging build and reload with both of the changes.
+	os.Remove(slowFile)
+	started := time.Now()
+	w.fire(p, otherFile)
+
+	select {
+	case changes := <-reloaded:
+		if expected := []string{p.MainFile, otherFile}; !reflect.DeepEqual(expected, changes) {
+			t.Fatalf("expected the changes %v but got %v", expected, changes)
+		}
+	case <-time.After(20 * time.Second):
+		t.Fatal("the stale build was not canceled")
+	}
+
+	if elapsed := time.Since(started); elapsed > 15*time.Second {
+		t.Fatalf("expected the reload to not wait for the stale build but it took %s", elapsed)
+	}
+}
+
+func TestReloadStageWritesWatchedFile(t *testing.T) {
+	if isWindows {
+		t.Skip("the stage command needs a shell")
+	}
+
+	p := newTestProgram(t, sleeperProgram)
+	// i.e a "go generate" stage, it rewrites a watched file on every reload.
+	p.Stages = []Stage{{Name: "generate", Command: []string{"sh", "-c", "printf 'package main\\n' > gen.go"}}}
+
+	reloaded := make(chan []string, 4)
+	p.OnReloaded = func(changes []ChangeEvent) {
+		reloaded <- changedPaths(changes)
+	}
+
+	r := New()
+	r.Add(p)
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- r.RunContext(ctx, newSignalWatcher(), nil, 0)
+	}()
+	defer func() {
+		cancel()
+		waitRunError(t, errCh)
+	}()
+
+	// the first run, the watcher has no started signal.
+	waitRuns(t, p, 1)
+
+	if err := os.WriteFile(p.MainFile, []byte(sleeperProgram+"\n// changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case changes := <-reloaded:
+		if expected := []string{p.MainFile}; !reflect.DeepEqual(expected, changes) {
+			t.Fatalf("expected the changes %v but got %v", expected, changes)
+		}
+	case <-time.After(30 * time.Second):
+		t.Fatal("the stage's own change canceled the build")
+	}
+
+	// the generated file is not a newer change, no more reloads.
+	waitRuns(t, p, 2)
+	if n := len(reloaded); n != 0 {
+		t.Fatalf("expected one reload but got %d more", n)
+	}
+}
+
+func TestReloadContentHash(t *testing.T) {
+	p := newTestProgram(t, sleeperProgram)
+
+	reloads := make(chan []string, 2)
+	p.OnReload = func(changes []ChangeEvent) {
+		reloads <- changedPaths(changes)
+	}
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+	}()
+
+	// a touch, the content is the same as the first build's one.
+	now := time.Now()
+	if err := os.Chtimes(p.MainFile, now, now); err != nil {
+		t.Fatal(err)
+	}
+	w.change(p, p.MainFile)
+	if n := len(reloads); n != 0 {
+		t.Fatalf("expected no reload without a content change but got %d", n)
+	}
+
+	if err := os.WriteFile(p.MainFile, []byte(sleeperProgram+"\n// changed\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w.change(p, p.MainFile)
+	if expected, got := []string{p.MainFile}, <-reloads; !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected a reload with the changes %v but got %v", expected, got)
+	}
+
+	// the same content as the last build's one.
+	w.change(p, p.MainFile)
+	if n := len(reloads); n != 0 {
+		t.Fatalf("expected no reload without a content change since the last build but got %d", n)
+	}
+}
+
+func TestReloadHookExit(t *testing.T) {
+	p := newTestProgram(t, sleeperProgram)
+	p.DisableContentHash = true
+	p.OnReloadHooks = []Hook{{Command: "go no-such-command", Policy: HookExit}}
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	w.fire(p, p.MainFile)
+
+	select {
+	case err := <-errCh:
+		var herr *hookError
+		if !errors.As(err, &herr) {
+			t.Fatalf("expected the error of the hook but got %v", err)
+		}
+	case <-time.After(30 * time.Second):
+		r.Stop()
+		t.Fatal("expected the failed hook to stop the runner")
+	}
+}
+
+func TestReloadedHooks(t *testing.T) {
+	if isWindows {
+		t.Skip("the hook command needs a shell")
+	}
+
+	p := newTestProgram(t, sleeperProgram)
+	p.DisableContentHash = true
+	reloadedFile := filepath.Join(p.dir, "reloaded")
+	p.OnReloadedHooks = []Hook{
+		{Command: "go no-such-command"}, // the reload is done, the rest of the hooks run.
+		{Command: `sh -c 'echo "$RIZLA_CHANGED_FILE" > reloaded'`},
+	}
+
+	r := New()
+	r.Add(p)
+	w, errCh := runInBackground(r, context.Background())
+	defer func() {
+		r.Stop()
+		waitRunError(t, errCh)
+	}()
+
+	if fileExists(reloadedFile) {
+		t.Fatal("expected the hooks to not run on the first run")
+	}
+
+	w.change(p, p.MainFile)
+	b, err := os.ReadFile(reloadedFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if expected, got := p.MainFile, strings.TrimSpace(string(b)); expected != got {
+		t.Fatalf("expected the changed file %s but got %s", expected, got)
+	}
+}
@@ -0,0 +1,94 @@
+package rizla
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// router routes the changes of the watched directories to the projects which watch them,
+// a directory can be watched by more than one project, i.e on nested or overlapping roots.
+type router struct {
+	dirs map[string][]*Project
+}
+
+func newRouter() *router {
+	return &router{dirs: make(map[string][]*Project)}
+}
+
+// add registers the "dir" as a watched directory of the project,
+// it reports whether it wasn't registered before.
+func (rt *router) add(dir string, p *Project) bool {
+	for _, existing := range rt.dirs[dir] {
+		if existing == p {
+			return false
+		}
+	}
+
+	rt.dirs[dir] = append(rt.dirs[dir], p)
+	return true
+}
+
+// remove unregisters the "dir", i.e when it's removed.
+func (rt *router) remove(dir string) {
+	delete(rt.dirs, dir)
+}
+
+// route returns the projects which watch the "path",
+// the projects of its directory and, if it's a watched directory itself, its own ones.
+func (rt *router) route(path string) []*Project {
+	projects := rt.dirs[filepath.Dir(path)]
+
+	own, ok := rt.dirs[path]
+	if !ok {
+		return projects
+	}
+
+	projects = append([]*Project(nil), projects...)
+	for _, p := range own {
+		found := false
+		for _, existing := range projects {
+			if existing == p {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			projects = append(projects, p)
+		}
+	}
+	return projects
+}
+
+// register walks the roots of the project and registers the directories which the project's Watcher accepts,
+// "watch" is called once for each of them.
+func (rt *router) register(p *Project, watch func(dir string) error) error {
+	for _, root := range p.watchRoots() {
+		err := filepath.Walk(root, func(path string, f os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !f.IsDir() {
+				return nil
+			}
+
+			// check if this subdir is allowed, the root is always watched.
+			if path != root && !p.Watcher(path) {
+				return filepath.SkipDir
+			}
+
+			rt.add(path, p)
+			if err := watch(path); err != nil {
+				p.Err.Errorf("\n%v\n", err)
+			}
+			return nil
+		})
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
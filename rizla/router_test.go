@@ -0,0 +1,127 @@
+package rizla
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// newRouterTestProject returns a project which watches the "root", which is created.
+func newRouterTestProject(t *testing.T, root string) *Project {
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+
+	p := newTestProject()
+	p.dir = root
+	p.DisableLocalModules = true
+	return p
+}
+
+func TestRouter(t *testing.T) {
+	root := t.TempDir()
+	a := newRouterTestProject(t, filepath.Join(root, "a"))
+	b := newRouterTestProject(t, filepath.Join(root, "b"))
+	// nested inside "a".
+	nested := newRouterTestProject(t, filepath.Join(root, "a", "nested"))
+	os.MkdirAll(filepath.Join(root, "a", "nested", "pkg"), os.ModePerm)
+	// nested inside "b" but "b" excludes it.
+	excluded := newRouterTestProject(t, filepath.Join(root, "b", "tmp"))
+	b.Exclude = []string{"tmp/"}
+
+	rt := newRouter()
+	for _, p := range []*Project{a, b, nested, excluded} {
+		if err := rt.register(p, func(string) error { return nil }); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path     string
+		projects []*Project
+	}{
+		{"a/main.go", []*Project{a}},
+		{"b/main.go", []*Project{b}},
+		{"a/nested/main.go", []*Project{a, nested}},
+		{"a/nested/pkg/pkg.go", []*Project{a, nested}},
+		{"b/tmp/main.go", []*Project{excluded}},
+		{"a/nested", []*Project{a, nested}}, // the directory itself.
+		{"c/main.go", nil},
+	}
+
+	for _, tt := range tests {
+		got := rt.route(filepath.Join(root, filepath.FromSlash(tt.path)))
+		if len(got) != len(tt.projects) {
+			t.Fatalf("%s: expected %d projects but got %d", tt.path, len(tt.projects), len(got))
+		}
+
+		for i := range got {
+			if got[i] != tt.projects[i] {
+				t.Fatalf("%s: expected the project of %s at %d but got the one of %s", tt.path, tt.projects[i].dir, i, got[i].dir)
+			}
+		}
+	}
+
+	// a directory which is created at runtime.
+	if !rt.add(filepath.Join(root, "b", "new"), b) || rt.add(filepath.Join(root, "b", "new"), b) {
+		t.Fatal("expected the directory to be added once")
+	}
+
+	rt.remove(filepath.Join(root, "a", "nested", "pkg"))
+	if got := rt.route(filepath.Join(root, "a", "nested", "pkg", "pkg.go")); len(got) != 0 {
+		t.Fatalf("expected no projects for a removed directory but got %d", len(got))
+	}
+}
+
+func TestSignalWatcherRouting(t *testing.T) {
+	root := t.TempDir()
+	a := newRouterTestProject(t, filepath.Join(root, "a"))
+	b := newRouterTestProject(t, filepath.Join(root, "b"))
+
+	events := make(chan ChangeEvent, 16)
+	w := newSignalWatcher()
+	w.OnChange(func(evt ChangeEvent) {
+		events <- evt
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- w.Loop(ctx, []*Project{a, b})
+	}()
+	defer func() {
+		cancel()
+		<-errCh
+	}()
+
+	// the watcher has no started signal, retry until the first change arrives.
+	filename := filepath.Join(a.dir, "main.go")
+	deadline := time.After(5 * time.Second)
+	for {
+		if err := os.WriteFile(filename, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		select {
+		case evt := <-events:
+			// and the rest of the changes of the write.
+			time.Sleep(200 * time.Millisecond)
+			for {
+				if evt.Project != a {
+					t.Fatalf("expected the change of %s to be routed to its project only but got the one of %s", filename, evt.Project.dir)
+				}
+
+				select {
+				case evt = <-events:
+				default:
+					return
+				}
+			}
+		case <-time.After(100 * time.Millisecond):
+		case <-deadline:
+			t.Fatal("no change detected")
+		}
+	}
+}
@@ -1,19 +1,18 @@
 package rizla
 
 import (
-	"os"
+	"context"
+	"errors"
 	"path/filepath"
 
 	"github.com/fsnotify/fsnotify"
 )
 
 type signalWatcher struct {
-	underline *fsnotify.Watcher
-	// to stop the for loop
-	stopChan           chan bool
-	hasStoppedManually bool
-	errListeners       []WatcherErrorListener
-	changeListeners    []WatcherChangeListener
+	errListeners    []WatcherErrorListener
+	changeListeners []WatcherChangeListener
+	// newSource returns the source of the file system's events, it's replaced by tests.
+	newSource func() (eventSource, error)
 }
 
 var _ Watcher = &signalWatcher{}
@@ -21,16 +20,37 @@ var _ Watcher = &signalWatcher{}
 // newSignalWatcher returns a new fsnotify wrapper
 // which watching the operating system's file system's signals.
 func newSignalWatcher() Watcher {
-	watcher, werr := fsnotify.NewWatcher()
-	if werr != nil {
-		// yes we should panic on these things.
-		panic(werr)
-	}
+	return &signalWatcher{newSource: newFsnotifySource}
+}
+
+// eventSource is the source of the file system's events of the signalWatcher.
+type eventSource interface {
+	// Add starts watching the directory.
+	Add(dir string) error
+	Events() <-chan fsnotify.Event
+	Errors() <-chan error
+	Close() error
+}
+
+// fsnotifySource is the eventSource of the operating system's file system's signals.
+type fsnotifySource struct {
+	*fsnotify.Watcher
+}
 
-	return &signalWatcher{
-		underline: watcher,
-		stopChan:  make(chan bool, 1),
+func newFsnotifySource() (eventSource, error) {
+	underline, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
 	}
+	return fsnotifySource{underline}, nil
+}
+
+func (s fsnotifySource) Events() <-chan fsnotify.Event {
+	return s.Watcher.Events
+}
+
+func (s fsnotifySource) Errors() <-chan error {
+	return s.Watcher.Errors
 }
 
 func (w *signalWatcher) OnError(evt WatcherErrorListener) {
@@ -41,101 +61,99 @@ func (w *signalWatcher) OnChange(evt WatcherChangeListener) {
 	w.changeListeners = append(w.changeListeners, evt)
 }
 
-func (w *signalWatcher) Stop() {
-	w.stopChan <- true
-	w.hasStoppedManually = true
-	w.underline.Close()
-}
-
-func (w *signalWatcher) Loop() {
-	// fsnotify needs to know the folder one by one, it doesn't cares about root's subdir yet.
-	// so:
-	for _, p := range projects {
-		// add to the watcher first in order to watch changes and re-builds if the first build has fallen
-
-		// add its root folder first
-		if err := w.underline.Add(p.dir); err != nil {
-			p.Err.Errorf("\n" + err.Error() + "\n")
-		}
+var errWatcherClosed = errors.New("rizla: file system watcher closed unexpectedly")
 
-		visitFn := func(path string, f os.FileInfo, err error) error {
-			if f.IsDir() {
-				// check if this subdir is allowed
-				if p.Watcher(path) {
-					if err := w.underline.Add(path); err != nil {
-						p.Err.Errorf("\n" + err.Error() + "\n")
-					}
-				} else {
-					return filepath.SkipDir
-				}
+func (w *signalWatcher) Loop(ctx context.Context, projects []*Project) error {
+	// the underline watcher is created on each Loop,
+	// so the signalWatcher can be re-used after a stop.
+	underline, err := w.newSource()
+	if err != nil {
+		return err
+	}
+	defer underline.Close()
 
-			}
-			return nil
-		}
+	// some systems report more than one event on a single save.
+	dedupe := newDedupeFilter(DefaultDuplicateWindow)
 
-		if err := filepath.Walk(p.dir, visitFn); err != nil {
-			// actually it never panics here but keep it for note
-			panic(err)
+	// fsnotify needs to know the folder one by one, it doesn't cares about root's subdir yet.
+	// so, add to the watcher first in order to watch changes and re-builds if the first build has fallen.
+	// Each change is routed only to the projects which watch its directory.
+	rt := newRouter()
+	for _, p := range projects {
+		if err := rt.register(p, underline.Add); err != nil {
+			return err
 		}
 	}
 
-	defer func() {
-		w.underline.Close()
-		for _, p := range projects {
-			killProcess(p.proc, p.AppName)
-		}
-		if !w.hasStoppedManually {
-			for i := range w.errListeners {
-				w.errListeners[i](errUnexpected)
-			}
-		}
-	}()
-
-	w.stopChan <- false
-
 	// run the watcher
 	for {
 		select {
-		case stop := <-w.stopChan:
-			if stop {
-				w.hasStoppedManually = true
-				return
+		case <-ctx.Done():
+			return context.Cause(ctx)
+
+		case event, ok := <-underline.Events():
+			if !ok {
+				return errWatcherClosed
 			}
 
-		case event := <-w.underline.Events:
-			// ignore CHMOD events
-			if event.Op&fsnotify.Chmod == fsnotify.Chmod {
+			op := fsnotifyOp(event.Op)
+			filename := event.Name
+			if dedupe.duplicate(filename, op) {
 				continue
 			}
 
-			filename := event.Name
-			for _, p := range projects {
-				p.i++
-				// fix two-times reload on windows
-				if isWindows && p.i%2 != 0 {
-					continue
+			for _, p := range rt.route(filename) {
+				if filepath.Base(filename) == ".gitignore" {
+					p.gitignores.refresh()
 				}
 
 				if !p.DisableRuntimeDir { // if add folders to watch at runtime is enabled
 					// if a folder created after the first Adds, add them here at runtime.
-					if isDirectory(filename) && p.Watcher(filename) {
-						if err := w.underline.Add(filename); err != nil {
-							p.Err.Errorf("\n" + err.Error() + "\n")
+					if isDirectory(filename) && p.Watcher(filename) && rt.add(filename, p) {
+						if err := underline.Add(filename); err != nil {
+							p.Err.Errorf("\n%v\n", err)
 						}
 					}
 				}
 
+				evt := NewChangeEvent(p, filename, op)
 				for i := range w.changeListeners {
-					w.changeListeners[i](p, filename)
+					w.changeListeners[i](evt)
 				}
+			}
 
+			if op&(Remove|Rename) != 0 {
+				// fsnotify stops watching a removed directory.
+				rt.remove(filename)
 			}
-		case err := <-w.underline.Errors:
-			if !w.hasStoppedManually {
-				for i := range w.errListeners {
-					w.errListeners[i](err)
-				}
+		case err, ok := <-underline.Errors():
+			if !ok {
+				return errWatcherClosed
+			}
+
+			for i := range w.errListeners {
+				w.errListeners[i](err)
 			}
 		}
 	}
 }
+
+// fsnotifyOp converts the operations of a fsnotify event to rizla's ones.
+func fsnotifyOp(fop fsnotify.Op) (op Op) {
+	if fop&fsnotify.Create == fsnotify.Create {
+		op |= Create
+	}
+	if fop&fsnotify.Write == fsnotify.Write {
+		op |= Write
+	}
+	if fop&fsnotify.Remove == fsnotify.Remove {
+		op |= Remove
+	}
+	if fop&fsnotify.Rename == fsnotify.Rename {
+		op |= Rename
+	}
+	if fop&fsnotify.Chmod == fsnotify.Chmod {
+		op |= Chmod
+	}
+	return
+}
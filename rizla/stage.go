@@ -0,0 +1,126 @@
+package rizla
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultStageTimeout is the default `Stage#Timeout`.
+var DefaultStageTimeout = 5 * time.Minute
+
+// Stage is a command which runs before the build of a project,
+// i.e a code generator, like "go generate ./...", or a linter, like "go vet ./...".
+// See `Project#Stages`.
+type Stage struct {
+	// Name is the name of the stage, it's shown on rizla's messages, i.e "generate".
+	// Defaults to the command.
+	Name string
+	// Command is the executable and its arguments, i.e []string{"templ", "generate"}.
+	Command []string
+	// Dir is the working directory of the command.
+	// Defaults to the main package's directory.
+	Dir string
+	// Triggers are the glob patterns of the changed files which run the stage, i.e []string{"*.templ"},
+	// their syntax is the one of the `Project#Include`.
+	// Defaults to nil, the stage runs on every reload.
+	Triggers []string
+	// Timeout is the maximum duration of the command, it's killed after that.
+	// Defaults to `DefaultStageTimeout`.
+	Timeout time.Duration
+}
+
+func (s Stage) name() string {
+	if s.Name != "" {
+		return s.Name
+	}
+	return strings.Join(s.Command, " ")
+}
+
+// triggered reports whether any of the "changes" runs the stage,
+// every stage runs on the first build, which has no changes.
+func (s Stage) triggered(changes []ChangeEvent) bool {
+	if len(s.Triggers) == 0 || changes == nil {
+		return true
+	}
+
+	triggers := compilePatterns(s.Triggers)
+	for _, evt := range changes {
+		if triggers.match(filepath.ToSlash(evt.RelPath), false) {
+			return true
+		}
+	}
+	return false
+}
+
+// stageError is the error of a failed stage.
+type stageError struct {
+	stage string
+	err   error
+}
+
+func (e *stageError) Error() string {
+	return fmt.Sprintf("stage %s: %v", e.stage, e.err)
+}
+
+func (e *stageError) Unwrap() error {
+	return e.err
+}
+
+// runStages runs the stages of the project which the "changes" trigger, in order,
+// it stops on the first failure.
+func runStages(ctx context.Context, p *Project, changes []ChangeEvent) error {
+	for _, s := range p.Stages {
+		if !s.triggered(changes) {
+			continue
+		}
+
+		if err := runStage(ctx, p, s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func runStage(ctx context.Context, p *Project, s Stage) error {
+	if len(s.Command) == 0 {
+		return &stageError{stage: s.name(), err: errors.New("empty command")}
+	}
+
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = DefaultStageTimeout
+	}
+
+	stageCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	p.Out.Debugf("%sRunning the %s stage", p.logPrefix(), s.name())
+
+	cmd := newGroupCommand(stageCtx, s.Command[0], s.Command[1:]...)
+	cmd.Dir = s.Dir
+	if cmd.Dir == "" {
+		cmd.Dir = p.dir
+	}
+	cmd.Env = append(os.Environ(), p.BuildEnv...)
+	cmd.Stdout = p.Out.Printer.Output
+	cmd.Stderr = p.Err.Printer.Output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+
+		if stageCtx.Err() == context.DeadlineExceeded {
+			err = fmt.Errorf("timed out after %s", timeout)
+		}
+		return &stageError{stage: s.name(), err: err}
+	}
+
+	return nil
+}
@@ -0,0 +1,90 @@
+package rizla
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStageTriggered(t *testing.T) {
+	p := newTestProject()
+	s := Stage{Command: []string{"templ", "generate"}, Triggers: []string{"*.templ", "sql/**/*.sql"}}
+
+	tests := []struct {
+		path      string
+		triggered bool
+	}{
+		{"views/index.templ", true},
+		{"sql/queries/users.sql", true},
+		{"main.go", false},
+		{"schema.sql", false},
+	}
+
+	for _, tt := range tests {
+		changes := []ChangeEvent{NewChangeEvent(p, filepath.Join(p.dir, filepath.FromSlash(tt.path)), Write)}
+		if got := s.triggered(changes); got != tt.triggered {
+			t.Fatalf("%s: expected triggered: %v but got %v", tt.path, tt.triggered, got)
+		}
+	}
+
+	if !s.triggered(nil) {
+		t.Fatal("expected every stage to run on the first build")
+	}
+}
+
+func TestRunStages(t *testing.T) {
+	p := newTestProject()
+	p.dir = t.TempDir()
+
+	p.Stages = []Stage{
+		{Name: "env", Command: []string{"go", "env", "GOROOT"}},
+		{Name: "broken", Command: []string{"go", "no-such-command"}},
+		{Name: "never", Command: []string{"go", "mod", "init", "never"}},
+	}
+
+	err := runStages(context.Background(), p, nil)
+	var serr *stageError
+	if !errors.As(err, &serr) || serr.stage != "broken" {
+		t.Fatalf("expected the broken stage to fail but got %v", err)
+	}
+
+	if _, err = os.Stat(filepath.Join(p.dir, "go.mod")); err == nil {
+		t.Fatal("expected the stages after the failed one to not run")
+	}
+
+	p.Stages = []Stage{{Name: "ok", Command: []string{"go", "env", "GOROOT"}}}
+	if err = runStages(context.Background(), p, nil); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRunStageTimeout(t *testing.T) {
+	if isWindows {
+		t.Skip("the stage command needs a shell")
+	}
+
+	p := newTestProject()
+	p.dir = t.TempDir()
+	p.Stages = []Stage{{Name: "slow", Command: []string{"sh", "-c", "sleep 30"}, Timeout: 100 * time.Millisecond}}
+
+	started := time.Now()
+	err := runStages(context.Background(), p, nil)
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatalf("expected a timeout error but got %v", err)
+	}
+
+	if elapsed := time.Since(started); elapsed > 10*time.Second {
+		t.Fatalf("expected the stage to be killed after its timeout but it took %s", elapsed)
+	}
+
+	// a stage is canceled with the reload.
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errBuildCanceled)
+	if err = runStages(ctx, p, nil); err != errBuildCanceled {
+		t.Fatalf("expected the cause of the cancellation but got %v", err)
+	}
+}
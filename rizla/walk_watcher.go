@@ -1,28 +1,27 @@
 package rizla
 
 import (
-	"errors"
-	"os"
+	"context"
+	"io/fs"
 	"path/filepath"
+	"sort"
+	"sync"
 	"time"
 )
 
 type walkWatcher struct {
-	// to stop the for loop
-	stopChan           chan bool
-	hasStoppedManually bool
-	errListeners       []WatcherErrorListener
-	changeListeners    []WatcherChangeListener
+	errListeners    []WatcherErrorListener
+	changeListeners []WatcherChangeListener
+	// interval the time between two snapshots, zero for the `DefaultWalkLoopSleep`.
+	interval time.Duration
 }
 
 var _ Watcher = &walkWatcher{}
 
 // newWalkWatcher returns a new golang's stdlib filepath.Walker's wrapper
-// which watching with every x milleseconds the projects' directories.
-func newWalkWatcher() Watcher {
-	return &walkWatcher{
-		stopChan: make(chan bool, 1),
-	}
+// which watching with every "interval" the projects' directories.
+func newWalkWatcher(interval time.Duration) Watcher {
+	return &walkWatcher{interval: interval}
 }
 
 func (w *walkWatcher) OnError(evt WatcherErrorListener) {
@@ -33,67 +32,157 @@ func (w *walkWatcher) OnChange(evt WatcherChangeListener) {
 	w.changeListeners = append(w.changeListeners, evt)
 }
 
-func (w *walkWatcher) Stop() {
-	w.stopChan <- true
+// DefaultWalkLoopSleep it's the sleep time of the loop when --walk flag is used(`filepath#Walk`),
+// the interval between two snapshots of the projects' files, if the watcher has no interval, see `WatcherFromFlag`.
+// Defaults to 1.35 second.
+var DefaultWalkLoopSleep = 1350 * time.Millisecond
+
+// fileState is the state of a file at a snapshot.
+type fileState struct {
+	size    int64
+	modTime time.Time
+	// inode the file's identity, if the system supports it, otherwise zero.
+	inode uint64
 }
 
-var errDoneNot = errors.New("done")
+// snapshot is the state of the watched files of a project, by their paths.
+type snapshot map[string]fileState
 
-// DefaultWalkLoopSleep it's the sleep time of the loop when --walk flag is used(`filepath#Walk`).
-// Defaults to 1.3 second.
-var DefaultWalkLoopSleep = 1350 * time.Second
+// walkChange is a change between two snapshots.
+type walkChange struct {
+	path string
+	op   Op
+}
 
-func (w *walkWatcher) loop(p *Project, stopChan chan bool) {
-	for {
-		select {
-		case stop := <-stopChan:
-			if stop {
-				return
+// takeSnapshot walks the "roots" of the project and returns the state of the files which the project watches,
+// the directories which are not accepted by the project's Watcher are skipped.
+func takeSnapshot(p *Project, roots []string) snapshot {
+	// the .gitignore files may be changed since the last snapshot.
+	p.gitignores.refresh()
+
+	snap := make(snapshot)
+	for _, root := range roots {
+		filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				// i.e removed while walking.
+				return nil
 			}
-		default:
-			filepath.Walk(p.dir, func(path string, info os.FileInfo, err error) error {
 
-				if filepath.Ext(path) == goExt && info.ModTime().After(p.lastChange) {
-					for i := range w.changeListeners {
-						w.changeListeners[i](p, path)
-					}
-					return errDoneNot
+			if d.IsDir() {
+				if path != root && !p.Watcher(path) {
+					return filepath.SkipDir
 				}
+				return nil
+			}
 
+			if !p.watches(path) {
 				return nil
-			})
-			// loop every 1.3 second.
-			time.Sleep(DefaultWalkLoopSleep)
-		}
-	}
-}
+			}
 
-func (w *walkWatcher) Loop() {
-	w.stopChan <- false
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
 
-	for _, p := range projects {
-		go w.loop(p, w.stopChan)
+			snap[path] = fileState{
+				size:    info.Size(),
+				modTime: info.ModTime(),
+				inode:   fileInode(info),
+			}
+			return nil
+		})
 	}
 
-	defer func() {
-		for _, p := range projects {
-			killProcess(p.proc, p.AppName)
+	return snap
+}
+
+// diffSnapshots returns the changes from the "prev" to the "next" snapshot, sorted by path.
+// A removed and a created file with the same inode is a rename,
+// which is reported as a rename of the old path and a create of the new one.
+func diffSnapshots(prev, next snapshot) []walkChange {
+	var changes []walkChange
+	removed := make(map[uint64]string)
+
+	for path, state := range prev {
+		if _, ok := next[path]; !ok {
+			if state.inode != 0 {
+				removed[state.inode] = path
+			}
+			changes = append(changes, walkChange{path, Remove})
 		}
-		if !w.hasStoppedManually {
-			for i := range w.errListeners {
-				w.errListeners[i](errUnexpected)
+	}
+
+	for path, state := range next {
+		old, ok := prev[path]
+		if !ok {
+			if oldPath, renamed := removed[state.inode]; renamed && state.inode != 0 {
+				for i := range changes {
+					if changes[i].path == oldPath {
+						changes[i].op = Rename
+					}
+				}
 			}
+			changes = append(changes, walkChange{path, Create})
+			continue
+		}
+
+		if old.size != state.size || !old.modTime.Equal(state.modTime) || old.inode != state.inode {
+			changes = append(changes, walkChange{path, Write})
 		}
-	}()
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].path < changes[j].path
+	})
+	return changes
+}
 
+func (w *walkWatcher) loop(ctx context.Context, p *Project, roots []string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	prev := takeSnapshot(p, roots)
 	for {
 		select {
-		case stop := <-w.stopChan:
-			if stop {
-				w.hasStoppedManually = true
-				return
-			}
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
 
+		next := takeSnapshot(p, roots)
+		for _, change := range diffSnapshots(prev, next) {
+			evt := NewChangeEvent(p, change.path, change.op)
+			p.Out.Debugf("%s%s", p.logPrefix(), evt)
+			for i := range w.changeListeners {
+				w.changeListeners[i](evt)
+			}
 		}
+		prev = next
+	}
+}
+
+func (w *walkWatcher) Loop(ctx context.Context, projects []*Project) error {
+	interval := w.interval
+	if interval <= 0 {
+		interval = DefaultWalkLoopSleep
 	}
+	if interval <= 0 {
+		interval = 1350 * time.Millisecond
+	}
+
+	wg := new(sync.WaitGroup)
+	for _, p := range projects {
+		// the roots are read from the go.mod and go.work files once, not on every snapshot.
+		roots := p.watchRoots()
+
+		wg.Add(1)
+		go func(p *Project, roots []string) {
+			w.loop(ctx, p, roots, interval)
+			wg.Done()
+		}(p, roots)
+	}
+
+	<-ctx.Done()
+	wg.Wait()
+	return context.Cause(ctx)
 }
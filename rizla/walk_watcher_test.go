@@ -0,0 +1,89 @@
+package rizla
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestDiffSnapshots(t *testing.T) {
+	now := time.Now()
+	prev := snapshot{
+		"main.go":    {size: 10, modTime: now, inode: 1},
+		"old.go":     {size: 10, modTime: now, inode: 2},
+		"removed.go": {size: 10, modTime: now, inode: 3},
+		"same.go":    {size: 10, modTime: now, inode: 4},
+		"saved.go":   {size: 10, modTime: now, inode: 5},
+	}
+	next := snapshot{
+		"main.go":  {size: 12, modTime: now, inode: 1},
+		"new.go":   {size: 10, modTime: now, inode: 2},
+		"same.go":  {size: 10, modTime: now, inode: 4},
+		"saved.go": {size: 10, modTime: now, inode: 6}, // replaced by an editor.
+		"added.go": {size: 1, modTime: now, inode: 7},
+	}
+
+	expected := []walkChange{
+		{"added.go", Create},
+		{"main.go", Write},
+		{"new.go", Create},
+		{"old.go", Rename},
+		{"removed.go", Remove},
+		{"saved.go", Write},
+	}
+
+	if got := diffSnapshots(prev, next); !reflect.DeepEqual(expected, got) {
+		t.Fatalf("expected changes:\n%v\nbut got:\n%v", expected, got)
+	}
+
+	if got := diffSnapshots(next, next); len(got) != 0 {
+		t.Fatalf("expected no changes between the same snapshots but got %v", got)
+	}
+}
+
+func TestTakeSnapshot(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"main.go", "README.md", "vendor/lib/lib.go", "cmd/api/main.go"} {
+		filename := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(filename), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filename, []byte("package main\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	p := newTestProject()
+	p.dir = root
+	p.DisableLocalModules = true
+
+	snap := takeSnapshot(p, p.watchRoots())
+	var got []string
+	for path := range snap {
+		rel, _ := filepath.Rel(root, path)
+		got = append(got, filepath.ToSlash(rel))
+	}
+
+	if expected := []string{"cmd/api/main.go", "main.go"}; len(got) != len(expected) || snap[filepath.Join(root, "main.go")].size == 0 {
+		t.Fatalf("expected the snapshot of %v but got %v", expected, got)
+	}
+
+	if _, ok := snap[filepath.Join(root, "cmd", "api", "main.go")]; !ok {
+		t.Fatalf("expected the files of the subdirectories to be in the snapshot but got %v", got)
+	}
+}
+
+func TestWalkWatcherInterval(t *testing.T) {
+	w, _ := WatcherFromFlag("walk", 500*time.Millisecond)
+	if expected, got := 500*time.Millisecond, w.(*walkWatcher).interval; expected != got {
+		t.Fatalf("expected interval %s but got %s", expected, got)
+	}
+
+	// the default interval is read when the watcher starts.
+	w, _ = WatcherFromFlag("walk")
+	if got := w.(*walkWatcher).interval; got != 0 {
+		t.Fatalf("expected no interval but got %s", got)
+	}
+}
@@ -0,0 +1,16 @@
+//go:build !windows
+
+package rizla
+
+import (
+	"os"
+	"syscall"
+)
+
+// fileInode returns the inode of the file, it's used to detect renames.
+func fileInode(info os.FileInfo) uint64 {
+	if st, ok := info.Sys().(*syscall.Stat_t); ok {
+		return uint64(st.Ino)
+	}
+	return 0
+}
@@ -0,0 +1,9 @@
+package rizla
+
+import "os"
+
+// fileInode returns zero, the os.FileInfo of windows doesn't contain the file's identity,
+// so the renames are reported as a remove and a create.
+func fileInode(info os.FileInfo) uint64 {
+	return 0
+}
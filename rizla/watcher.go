@@ -1,12 +1,16 @@
 package rizla
 
+import (
+	"context"
+	"time"
+)
+
 type (
 	// WatcherErrorListener the form the OnError event listener.
 	WatcherErrorListener func(error)
 	// WatcherChangeListener the form the OnChage event listener.
-	// Receives the project which the change is valid (see Project.AllowReloadAfter)
-	// and a second parameter which is the relative path to the changed file or directory.
-	WatcherChangeListener func(p *Project, filename string)
+	// Receives the change of a file or a directory, its Project is the project which watches it.
+	WatcherChangeListener func(evt ChangeEvent)
 
 	// Watcher a common interface which file system watchers should implement.
 	Watcher interface {
@@ -15,20 +19,26 @@ type (
 		// OnError registers an event listener which fires when a watcher error occurs.
 		OnError(WatcherErrorListener)
 
-		// Loop starts the watching and the loop.
-		Loop()
-		// Stop terminates the watcher.
-		Stop()
+		// Loop starts watching the given projects and blocks until the "ctx" is done
+		// or the watcher fails.
+		// It returns the context's cause or the watcher's failure.
+		Loop(ctx context.Context, projects []*Project) error
 	}
 )
 
 // WatcherFromFlag returns a new Watcher based on a string flah.
 // This method keeps the watchers in the same spot.
 //
+// The optional "interval" is the polling interval of the walk watcher, defaults to `DefaultWalkLoopSleep`.
+//
 // Note: this is why the internal watchers are not exported.
-func WatcherFromFlag(flag string) (Watcher, bool) {
+func WatcherFromFlag(flag string, interval ...time.Duration) (Watcher, bool) {
 	if flag == "-w" || flag == "-walk" || flag == "walk" {
-		return newWalkWatcher(), true
+		var d time.Duration
+		if len(interval) > 0 {
+			d = interval[0]
+		}
+		return newWalkWatcher(d), true
 	}
 
 	if flag == "-s" || flag == "-signal" || flag == "signal" || flag == "default" {